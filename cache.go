@@ -0,0 +1,273 @@
+package wappalyzer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/projectdiscovery/wappalyzergo/internal/ahocorasick"
+)
+
+// cacheVersion is the version of the fingerprints cache format.
+// It must be increased whenever the cached types change.
+const cacheVersion = 2
+
+// cacheVersions identifies the versions of the cache format and of the
+// algorithms building the cached data. It is part of the content hash of
+// the sources, so that the caches written by other versions are stale.
+var cacheVersions = fmt.Sprintf("wappalyzergo cache %d prefilter %d %d %d %v",
+	cacheVersion, prefilterVersion, minPrefilterLiteralLength, maxPrefilterAlternatives, prefilterParts)
+
+var registerCacheTypesOnce sync.Once
+
+// registerCacheTypes registers the dynamic types decoded from the JSON
+// dom rules, which gob needs to encode them as interface values.
+func registerCacheTypes() {
+	registerCacheTypesOnce.Do(func() {
+		gob.Register(map[string]interface{}{})
+		gob.Register([]interface{}{})
+	})
+}
+
+// cacheHeader is the header of a fingerprints cache file
+type cacheHeader struct {
+	Version int
+	// Hash is the content hash of the sources the cache was built from
+	Hash string
+}
+
+// cachePayload is the content of a fingerprints cache file
+type cachePayload struct {
+	Fingerprints *Fingerprints
+	Report       *LoadReport
+	// Prefilters contains the prefilters built from the fingerprints, by
+	// part. It is only written by instances created with WithCache.
+	Prefilters map[part]*prefilterData
+}
+
+// prefilterData is the serialized form of a prefilter
+type prefilterData struct {
+	Matcher     *ahocorasick.Matcher
+	LiteralApps [][]string
+	Always      []string
+	Apps        []string
+}
+
+// hash returns the content hash of the sources of the loader.
+// It returns false if a source cannot be hashed.
+func (l *Loader) hash() (string, bool, error) {
+	hasher := sha256.New()
+	fmt.Fprintln(hasher, cacheVersions)
+	for _, source := range l.sources {
+		if source.hash == nil {
+			return "", false, nil
+		}
+		sum, err := source.hash()
+		if err != nil {
+			return "", false, fmt.Errorf("could not hash fingerprints from %s: %w", source.Name, err)
+		}
+		fmt.Fprintf(hasher, "%q %d %x\n", source.Name, source.Strategy, sum)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), true, nil
+}
+
+// LoadCached loads the fingerprints from the cache file if it was built
+// from the same sources, or from the sources otherwise, writing the
+// cache file for the next loads.
+//
+// The cache file holds the merged fingerprints in a binary form along
+// with a content hash of the sources, so a later load with unchanged
+// sources does not parse any JSON. Sources without a content hash, such
+// as MemorySource, disable the cache. Failures to write the cache file
+// are ignored as the fingerprints are loaded anyway.
+//
+// Only the fingerprints are cached, instances created with WithCache
+// also cache the prefilters built from them.
+func (l *Loader) LoadCached(cachePath string) (*Fingerprints, *LoadReport, error) {
+	sum, ok, err := l.hash()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return l.Load()
+	}
+
+	if payload, err := readCache(cachePath, sum); err == nil {
+		return payload.Fingerprints, payload.Report, nil
+	}
+
+	fingerprints, report, err := l.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	_ = writeCache(cachePath, sum, &cachePayload{Fingerprints: fingerprints, Report: report})
+	return fingerprints, report, nil
+}
+
+// loadCached loads the fingerprints of the instance like LoadCached. The
+// cache file also holds the prefilters built from the fingerprints, so
+// that a later load with unchanged sources does not build them again.
+func (s *Wappalyze) loadCached(loader *Loader) error {
+	sum, ok, err := loader.hash()
+	if err != nil {
+		return err
+	}
+	if ok {
+		if payload, err := readCache(s.cachePath, sum); err == nil {
+			s.loadReport = payload.Report
+			if err := s.loadFingerprints(payload.Fingerprints); err != nil {
+				return err
+			}
+			if len(payload.Prefilters) == 0 {
+				// Cache written by Loader.LoadCached, add the prefilters
+				payload.Prefilters = s.fingerprints.exportPrefilters()
+				_ = writeCache(s.cachePath, sum, payload)
+				return nil
+			}
+			s.fingerprints.setPrefilters(payload.Prefilters)
+			return nil
+		}
+	}
+
+	fingerprints, report, err := loader.Load()
+	if err != nil {
+		return err
+	}
+	s.loadReport = report
+	if err := s.loadFingerprints(fingerprints); err != nil {
+		return err
+	}
+	if ok {
+		// The diagnostics are collected again when loading from the cache
+		cached := *report
+		cached.Diagnostics = nil
+		payload := &cachePayload{Fingerprints: fingerprints, Report: &cached, Prefilters: s.fingerprints.exportPrefilters()}
+		_ = writeCache(s.cachePath, sum, payload)
+	}
+	return nil
+}
+
+// exportPrefilters builds the prefilters of all the parts for the cache
+func (f *CompiledFingerprints) exportPrefilters() map[part]*prefilterData {
+	prefilters := make(map[part]*prefilterData, len(prefilterParts))
+	for _, part := range prefilterParts {
+		filter := f.getPrefilter(part)
+		prefilters[part] = &prefilterData{
+			Matcher:     filter.matcher,
+			LiteralApps: filter.literalApps,
+			Always:      filter.always,
+			Apps:        filter.apps,
+		}
+	}
+	return prefilters
+}
+
+// setPrefilters sets the prefilters read from the cache. The
+// prefilters missing from the cache are built on first use.
+func (f *CompiledFingerprints) setPrefilters(prefilters map[part]*prefilterData) {
+	for part, data := range prefilters {
+		if !slices.Contains(prefilterParts, part) || data == nil || data.Matcher == nil {
+			continue
+		}
+		f.indexes.prefilters[part] = &prefilter{
+			matcher:     data.Matcher,
+			literalApps: data.LiteralApps,
+			always:      data.Always,
+			apps:        data.Apps,
+		}
+	}
+}
+
+// readCache reads a cache file, checking it was built from sources with the hash
+func readCache(cachePath, sum string) (*cachePayload, error) {
+	file, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	registerCacheTypes()
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+
+	var header cacheHeader
+	if err := decoder.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != cacheVersion || header.Hash != sum {
+		return nil, fmt.Errorf("stale fingerprints cache: %s", cachePath)
+	}
+
+	var payload cachePayload
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+	if payload.Fingerprints == nil || payload.Report == nil {
+		return nil, fmt.Errorf("invalid fingerprints cache: %s", cachePath)
+	}
+	return &payload, nil
+}
+
+// writeCache writes a cache file, replacing the existing one atomically
+func writeCache(cachePath, sum string, payload *cachePayload) error {
+	file, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	registerCacheTypes()
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+	if err := encoder.Encode(&cacheHeader{Version: cacheVersion, Hash: sum}); err != nil {
+		return err
+	}
+	if err := encoder.Encode(payload); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), cachePath)
+}
+
+// hashFile returns the content hash of a file
+func hashFile(filePath string) ([]byte, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// hashFS returns the content hash of the fingerprints JSON files of a file system
+func hashFS(fsys fs.FS) ([]byte, error) {
+	hasher := sha256.New()
+	err := walkFingerprintFiles(fsys, func(path string, data []byte) error {
+		writeHashField(hasher, []byte(path))
+		writeHashField(hasher, data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// writeHashField writes a length prefixed field to a hash
+func writeHashField(hasher hash.Hash, data []byte) {
+	fmt.Fprintf(hasher, "%d:", len(data))
+	hasher.Write(data)
+}
@@ -0,0 +1,150 @@
+package wappalyzer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCached(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "fingerprints.cache")
+	filePath := filepath.Join(dir, "custom.json")
+	err := os.WriteFile(filePath, []byte(`{"apps": {"Custom App": {"headers": {"x-custom": ""}, "dom": {"#app": {"attributes": {"data-version": "([\\d.]+)\\;version:\\1"}}}}}}`), 0644)
+	require.Nil(t, err, "could not write fingerprints file")
+
+	loaded, report, err := NewLoader(EmbeddedSource(), FileSource(filePath)).LoadCached(cachePath)
+	require.Nil(t, err, "could not load fingerprints")
+	require.FileExists(t, cachePath, "could not write cache")
+
+	// A source failing to load can only be loaded from the cache
+	cachedSource := func(source Source) Source {
+		source.load = func() (map[string]*Fingerprint, error) {
+			return nil, errors.New("not cached")
+		}
+		return source
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		cached, cachedReport, err := NewLoader(cachedSource(EmbeddedSource()), cachedSource(FileSource(filePath))).LoadCached(cachePath)
+		require.Nil(t, err, "could not load cached fingerprints")
+		require.Len(t, cached.Apps, len(loaded.Apps), "could not load all cached fingerprints")
+		require.Equal(t, report.Sources, cachedReport.Sources, "could not load cached report")
+		require.Equal(t, loaded.Apps["Custom App"].Dom, cached.Apps["Custom App"].Dom, "could not load cached dom rules")
+
+		wappalyze := &Wappalyze{fingerprints: &CompiledFingerprints{Apps: make(map[string]*CompiledFingerprint)}}
+		err = wappalyze.loadFingerprints(cached)
+		require.Nil(t, err, "could not compile cached fingerprints")
+		fingerprints := wappalyze.Fingerprint(map[string][]string{"Server": {"nginx/1.18.0"}, "X-Custom": {"1"}}, nil)
+		require.Equal(t, map[string]struct{}{"Nginx:1.18.0": {}, "Custom App": {}}, fingerprints, "could not get correct match")
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		err := os.WriteFile(filePath, []byte(`{"apps": {"Other App": {"headers": {"x-other": ""}}}}`), 0644)
+		require.Nil(t, err, "could not write fingerprints file")
+
+		_, _, err = NewLoader(cachedSource(EmbeddedSource()), cachedSource(FileSource(filePath))).LoadCached(cachePath)
+		require.NotNil(t, err, "could load stale cache")
+
+		updated, _, err := NewLoader(EmbeddedSource(), FileSource(filePath)).LoadCached(cachePath)
+		require.Nil(t, err, "could not load updated fingerprints")
+		require.Contains(t, updated.Apps, "Other App", "could not load updated fingerprints")
+		require.NotContains(t, updated.Apps, "Custom App", "could load stale fingerprints")
+	})
+
+	t.Run("option", func(t *testing.T) {
+		wappalyzer, err := NewFromFile(filePath, true, true, WithCache(cachePath))
+		require.Nil(t, err, "could not create wappalyzer")
+		require.Contains(t, wappalyzer.GetFingerprints().Apps, "Other App", "could not load cached fingerprints")
+	})
+}
+
+func TestCachedPrefilters(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "fingerprints.cache")
+
+	_, err := NewFromLoader(NewLoader(EmbeddedSource()), WithCache(cachePath))
+	require.Nil(t, err, "could not create wappalyzer")
+	payload, err := readCacheOf(t, cachePath)
+	require.Nil(t, err, "could not read cache")
+	require.Len(t, payload.Prefilters, len(prefilterParts), "could not cache prefilters")
+
+	cached, err := NewFromLoader(NewLoader(EmbeddedSource()), WithCache(cachePath))
+	require.Nil(t, err, "could not create cached wappalyzer")
+	uncached, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+
+	headers := map[string][]string{"Server": {"nginx/1.18.0"}}
+	body := []byte(`<html><head><script src="/wp-includes/js/jquery/jquery.min.js?ver=3.6.0"></script></head><body><div id="__next"></div></body></html>`)
+	require.Equal(t, uncached.Fingerprint(headers, body), cached.Fingerprint(headers, body), "could not get same matches from cached prefilters")
+	for _, part := range prefilterParts {
+		require.Equal(t, uncached.fingerprints.getPrefilter(part).apps, cached.fingerprints.getPrefilter(part).apps, "could not get same prefilter apps")
+	}
+}
+
+func TestCacheVersions(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "fingerprints.cache")
+	_, err := NewFromLoader(NewLoader(EmbeddedSource()), WithCache(cachePath))
+	require.Nil(t, err, "could not create wappalyzer")
+	_, err = readCacheOf(t, cachePath)
+	require.Nil(t, err, "could not read cache")
+
+	previous := cacheVersions
+	t.Cleanup(func() {
+		cacheVersions = previous
+	})
+	cacheVersions = strings.Replace(cacheVersions, fmt.Sprintf("prefilter %d", prefilterVersion), fmt.Sprintf("prefilter %d", prefilterVersion+1), 1)
+	require.NotEqual(t, previous, cacheVersions, "could not change prefilter version")
+
+	_, err = readCacheOf(t, cachePath)
+	require.NotNil(t, err, "could read cache of another prefilter version")
+
+	_, err = NewFromLoader(NewLoader(EmbeddedSource()), WithCache(cachePath))
+	require.Nil(t, err, "could not create wappalyzer")
+	payload, err := readCacheOf(t, cachePath)
+	require.Nil(t, err, "could not rewrite stale cache")
+	require.Len(t, payload.Prefilters, len(prefilterParts), "could not rewrite stale prefilters")
+}
+
+// readCacheOf reads a cache file whatever the hash of its sources
+func readCacheOf(t *testing.T, cachePath string) (*cachePayload, error) {
+	sum, _, err := NewLoader(EmbeddedSource()).hash()
+	require.Nil(t, err, "could not hash sources")
+	return readCache(cachePath, sum)
+}
+
+// BenchmarkNewCached measures the creation of an instance from the
+// embedded fingerprints and its first scan, without and with the
+// fingerprints cache. The first scan builds the indexes that are not
+// cached.
+func BenchmarkNewCached(b *testing.B) {
+	headers := map[string][]string{"Server": {"nginx/1.18.0"}}
+	body := []byte(strings.Repeat(`<div class="container"><script src="/js/app.js"></script></div>`, 100))
+
+	run := func(b *testing.B, options ...Option) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			wappalyzer, err := NewFromLoader(NewLoader(EmbeddedSource()), options...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			wappalyzer.Fingerprint(headers, body)
+		}
+	}
+
+	b.Run("uncached", func(b *testing.B) {
+		run(b)
+	})
+	b.Run("cached", func(b *testing.B) {
+		cachePath := filepath.Join(b.TempDir(), "fingerprints.cache")
+		if _, err := NewFromLoader(NewLoader(EmbeddedSource()), WithCache(cachePath)); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		run(b, WithCache(cachePath))
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// patternFields are the fields of an app holding detection patterns
+var patternFields = []struct {
+	name  string
+	value func(OutputFingerprint) interface{}
+}{
+	{"css", func(f OutputFingerprint) interface{} { return f.CSS }},
+	{"cookies", func(f OutputFingerprint) interface{} { return f.Cookies }},
+	{"dom", func(f OutputFingerprint) interface{} { return f.DOM }},
+	{"js", func(f OutputFingerprint) interface{} { return f.JS }},
+	{"headers", func(f OutputFingerprint) interface{} { return f.Headers }},
+	{"html", func(f OutputFingerprint) interface{} { return f.HTML }},
+	{"scripts", func(f OutputFingerprint) interface{} { return f.Script }},
+	{"scriptSrc", func(f OutputFingerprint) interface{} { return f.ScriptSrc }},
+	{"meta", func(f OutputFingerprint) interface{} { return f.Meta }},
+}
+
+// appChange is a change to an app
+type appChange struct {
+	app     string
+	details string
+}
+
+// diffReport describes the changes between the local and the updated data
+type diffReport struct {
+	added    []string
+	removed  []string
+	patterns []appChange
+	implies  []appChange
+	cats     []appChange
+	// categories are the changes to the categories definitions
+	categories []string
+}
+
+// diffFingerprints compares the local apps and categories with the updated ones
+func diffFingerprints(oldApps, newApps map[string]OutputFingerprint, oldCategories, newCategories map[int]Category) *diffReport {
+	report := &diffReport{}
+
+	for _, app := range sortedKeys(newApps) {
+		updated := newApps[app]
+		previous, ok := oldApps[app]
+		if !ok {
+			report.added = append(report.added, app)
+			continue
+		}
+
+		var fields []string
+		for _, field := range patternFields {
+			if !equalValues(field.value(previous), field.value(updated)) {
+				fields = append(fields, field.name)
+			}
+		}
+		if len(fields) > 0 {
+			report.patterns = append(report.patterns, appChange{app: app, details: strings.Join(fields, ", ")})
+		}
+
+		if details := diffStrings(previous.Implies, updated.Implies); details != "" {
+			report.implies = append(report.implies, appChange{app: app, details: details})
+		}
+		if !equalValues(previous.Cats, updated.Cats) {
+			report.cats = append(report.cats, appChange{app: app, details: fmt.Sprintf("%v -> %v", previous.Cats, updated.Cats)})
+		}
+	}
+	for _, app := range sortedKeys(oldApps) {
+		if _, ok := newApps[app]; !ok {
+			report.removed = append(report.removed, app)
+		}
+	}
+
+	ids := make(map[int]struct{})
+	for id := range oldCategories {
+		ids[id] = struct{}{}
+	}
+	for id := range newCategories {
+		ids[id] = struct{}{}
+	}
+	for _, id := range sortedKeys(ids) {
+		previous, hadPrevious := oldCategories[id]
+		updated, hasUpdated := newCategories[id]
+		switch {
+		case !hadPrevious:
+			report.categories = append(report.categories, fmt.Sprintf("added %d %q", id, updated.Name))
+		case !hasUpdated:
+			report.categories = append(report.categories, fmt.Sprintf("removed %d %q", id, previous.Name))
+		case previous.Name != updated.Name:
+			report.categories = append(report.categories, fmt.Sprintf("renamed %d %q -> %q", id, previous.Name, updated.Name))
+		case !equalValues(previous, updated):
+			report.categories = append(report.categories, fmt.Sprintf("changed %d %q", id, updated.Name))
+		}
+	}
+	return report
+}
+
+// empty returns true if nothing changed
+func (r *diffReport) empty() bool {
+	return len(r.added) == 0 && len(r.removed) == 0 && len(r.patterns) == 0 &&
+		len(r.implies) == 0 && len(r.cats) == 0 && len(r.categories) == 0
+}
+
+// write writes the report in a human readable form
+func (r *diffReport) write(w io.Writer) {
+	if r.empty() {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	writeSection := func(title string, lines []string) {
+		if len(lines) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "%s (%d):\n", title, len(lines))
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	changeLines := func(changes []appChange) []string {
+		lines := make([]string, 0, len(changes))
+		for _, change := range changes {
+			lines = append(lines, change.app+": "+change.details)
+		}
+		return lines
+	}
+
+	writeSection("Added apps", r.added)
+	writeSection("Removed apps", r.removed)
+	writeSection("Changed patterns", changeLines(r.patterns))
+	writeSection("Changed implies", changeLines(r.implies))
+	writeSection("Changed app categories", changeLines(r.cats))
+	writeSection("Changed categories", r.categories)
+}
+
+// diffStrings describes the strings added and removed from a list
+func diffStrings(previous, updated []string) string {
+	previousSet := make(map[string]struct{}, len(previous))
+	for _, item := range previous {
+		previousSet[item] = struct{}{}
+	}
+	updatedSet := make(map[string]struct{}, len(updated))
+	for _, item := range updated {
+		updatedSet[item] = struct{}{}
+	}
+
+	var changes []string
+	for _, item := range sortedKeys(updatedSet) {
+		if _, ok := previousSet[item]; !ok {
+			changes = append(changes, "+"+item)
+		}
+	}
+	for _, item := range sortedKeys(previousSet) {
+		if _, ok := updatedSet[item]; !ok {
+			changes = append(changes, "-"+item)
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+// equalValues compares two values, considering nil and empty
+// maps and slices as equal.
+func equalValues(a, b interface{}) bool {
+	if isEmpty(a) && isEmpty(b) {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func isEmpty(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[K string | int, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
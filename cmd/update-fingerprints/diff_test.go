@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffFingerprints(t *testing.T) {
+	base := OutputFingerprint{Cats: []int{1}, Headers: map[string]string{"server": "nginx"}, Implies: []string{"PHP"}}
+
+	tests := []struct {
+		name          string
+		oldApps       map[string]OutputFingerprint
+		newApps       map[string]OutputFingerprint
+		oldCategories map[int]Category
+		newCategories map[int]Category
+		expected      *diffReport
+	}{
+		{
+			name:     "unchanged",
+			oldApps:  map[string]OutputFingerprint{"App": base},
+			newApps:  map[string]OutputFingerprint{"App": {Cats: []int{1}, Headers: map[string]string{"server": "nginx"}, Implies: []string{"PHP"}, Cookies: map[string]string{}}},
+			expected: &diffReport{},
+		},
+		{
+			name:     "added and removed",
+			oldApps:  map[string]OutputFingerprint{"Old": base},
+			newApps:  map[string]OutputFingerprint{"New": base},
+			expected: &diffReport{added: []string{"New"}, removed: []string{"Old"}},
+		},
+		{
+			name:     "patterns",
+			oldApps:  map[string]OutputFingerprint{"App": base},
+			newApps:  map[string]OutputFingerprint{"App": {Cats: []int{1}, Headers: map[string]string{"server": "apache"}, HTML: []string{"<html"}, Implies: []string{"PHP"}}},
+			expected: &diffReport{patterns: []appChange{{app: "App", details: "headers, html"}}},
+		},
+		{
+			name:     "implies and cats",
+			oldApps:  map[string]OutputFingerprint{"App": base},
+			newApps:  map[string]OutputFingerprint{"App": {Cats: []int{1, 2}, Headers: map[string]string{"server": "nginx"}, Implies: []string{"MySQL"}}},
+			expected: &diffReport{implies: []appChange{{app: "App", details: "+MySQL, -PHP"}}, cats: []appChange{{app: "App", details: "[1] -> [1 2]"}}},
+		},
+		{
+			name:          "categories",
+			oldCategories: map[int]Category{1: {Name: "CMS"}, 2: {Name: "Blogs"}, 3: {Name: "Old"}},
+			newCategories: map[int]Category{1: {Name: "CMS", Priority: 1}, 2: {Name: "Blog"}, 4: {Name: "New"}},
+			expected: &diffReport{categories: []string{
+				`changed 1 "CMS"`,
+				`renamed 2 "Blogs" -> "Blog"`,
+				`removed 3 "Old"`,
+				`added 4 "New"`,
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := diffFingerprints(test.oldApps, test.newApps, test.oldCategories, test.newCategories)
+			require.Equal(t, test.expected, report, "could not get correct report")
+		})
+	}
+
+	t.Run("write", func(t *testing.T) {
+		var buffer bytes.Buffer
+		(&diffReport{}).write(&buffer)
+		require.Equal(t, "No changes\n", buffer.String(), "could not write empty report")
+
+		buffer.Reset()
+		(&diffReport{added: []string{"New"}, implies: []appChange{{app: "App", details: "+MySQL"}}}).write(&buffer)
+		require.Equal(t, "Added apps (1):\n  New\nChanged implies (1):\n  App: +MySQL\n", buffer.String(), "could not write report")
+	})
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Category is a single category of technologies
+type Category struct {
+	Groups   []int  `json:"groups,omitempty"`
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+}
+
+// miscellaneousCategory is the category of the apps without a known category
+const miscellaneousCategory = 19
+
+// managedFields are the app fields written from the upstream fingerprints.
+// The other fields of the local apps, such as browser, are kept as is.
+var managedFields = jsonFields(reflect.TypeOf(OutputFingerprint{}))
+
+// jsonFields returns the JSON names of the fields of a struct type
+func jsonFields(structType reflect.Type) map[string]struct{} {
+	fields := make(map[string]struct{})
+	for i := 0; i < structType.NumField(); i++ {
+		name, _, _ := strings.Cut(structType.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = struct{}{}
+		}
+	}
+	return fields
+}
+
+// readLocalApps reads the apps of the fingerprints files of a directory.
+// A missing directory has no apps.
+func readLocalApps(dir string) (map[string]OutputFingerprint, error) {
+	apps := make(map[string]OutputFingerprint)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var fingerprints struct {
+			Apps map[string]json.RawMessage `json:"apps"`
+		}
+		if err := json.Unmarshal(data, &fingerprints); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", file, err)
+		}
+		for app, raw := range fingerprints.Apps {
+			fingerprint, err := decodeLocalApp(raw)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s in %s: %w", app, file, err)
+			}
+			apps[app] = fingerprint
+		}
+	}
+	return apps, nil
+}
+
+// readLocalCategories reads the categories file of the data directory.
+// A missing file has no categories.
+func readLocalCategories(filePath string) (map[int]Category, error) {
+	categories := make(map[int]Category)
+
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return categories, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := parseCategories(data, categories); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", filePath, err)
+	}
+	return categories, nil
+}
+
+// parseCategories parses a categories file keyed by category ID
+func parseCategories(data []byte, categories map[int]Category) error {
+	var parsed map[string]Category
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	for key, category := range parsed {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("invalid category ID %q", key)
+		}
+		categories[id] = category
+	}
+	return nil
+}
+
+// decodeLocalApp decodes an app of a fingerprints file, keeping
+// the fields not written from the upstream fingerprints as is.
+func decodeLocalApp(raw json.RawMessage) (OutputFingerprint, error) {
+	var fingerprint OutputFingerprint
+	if err := json.Unmarshal(raw, &fingerprint); err != nil {
+		return fingerprint, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fingerprint, err
+	}
+	for name, value := range fields {
+		if _, ok := managedFields[name]; ok {
+			continue
+		}
+		if fingerprint.Extra == nil {
+			fingerprint.Extra = make(map[string]json.RawMessage)
+		}
+		fingerprint.Extra[name] = value
+	}
+	return fingerprint, nil
+}
+
+// mergeLocalApps merges the fields added locally to the apps into the
+// upstream apps. Local apps not present upstream are kept unless pruned.
+func mergeLocalApps(upstream *OutputFingerprints, local map[string]OutputFingerprint, prune bool) {
+	var kept, pruned int
+	for app, fingerprint := range local {
+		output, ok := upstream.Apps[app]
+		if !ok {
+			if prune {
+				pruned++
+				continue
+			}
+			upstream.Apps[app] = fingerprint
+			kept++
+			continue
+		}
+		output.Extra = fingerprint.Extra
+		upstream.Apps[app] = output
+	}
+	if kept > 0 {
+		log.Printf("Kept %d local apps not present upstream\n", kept)
+	}
+	if pruned > 0 {
+		log.Printf("Removed %d local apps not present upstream\n", pruned)
+	}
+}
+
+// mergeLocalCategories adds the local categories not present upstream
+// to the upstream categories, as local apps may still use them.
+func mergeLocalCategories(upstream, local map[int]Category) {
+	var kept int
+	for id, category := range local {
+		if _, ok := upstream[id]; ok {
+			continue
+		}
+		upstream[id] = category
+		kept++
+	}
+	if kept > 0 {
+		log.Printf("Kept %d local categories not present upstream\n", kept)
+	}
+}
+
+// categorySlugRegex matches the characters removed from the category file names
+var categorySlugRegex = regexp.MustCompile(`[^a-z0-9 ]`)
+
+// categoryFileName returns the name of the fingerprints file of a category
+func categoryFileName(id int, category Category) string {
+	slug := strings.ToLower(category.Name)
+	slug = categorySlugRegex.ReplaceAllString(slug, "")
+	slug = strings.Join(strings.Fields(slug), "-")
+	return fmt.Sprintf("%03d-%s.json", id, slug)
+}
+
+// appCategory returns the category whose file contains the app,
+// the first known category of the app.
+func appCategory(fingerprint OutputFingerprint, categories map[int]Category) int {
+	for _, cat := range fingerprint.Cats {
+		if _, ok := categories[cat]; ok {
+			return cat
+		}
+	}
+	return miscellaneousCategory
+}
+
+// writeFingerprintFiles writes the apps split by category into the
+// fingerprints directory, removing the files of categories without apps.
+func writeFingerprintFiles(dir string, fingerprints *OutputFingerprints, categories map[int]Category) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	files := make(map[string][]string)
+	for app, fingerprint := range fingerprints.Apps {
+		id := appCategory(fingerprint, categories)
+		name := categoryFileName(id, categories[id])
+		files[name] = append(files[name], app)
+	}
+
+	for name, apps := range files {
+		sort.Strings(apps)
+
+		var buffer bytes.Buffer
+		buffer.WriteString(`{"apps":{`)
+		for i, app := range apps {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			data, err := marshalApp(fingerprints.Apps[app])
+			if err != nil {
+				return fmt.Errorf("could not marshal %s: %w", app, err)
+			}
+			key, err := marshalJSON(app)
+			if err != nil {
+				return err
+			}
+			buffer.Write(key)
+			buffer.WriteByte(':')
+			buffer.Write(data)
+		}
+		buffer.WriteString(`}}`)
+
+		if err := writeIndentedJSON(filepath.Join(dir, name), buffer.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range existing {
+		if _, ok := files[filepath.Base(file)]; ok {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+		log.Printf("Removed %s, no apps are left in its category\n", file)
+	}
+	log.Printf("Wrote %d apps to %d files in %s\n", len(fingerprints.Apps), len(files), dir)
+	return nil
+}
+
+// writeCategoriesFile writes the categories sorted by ID
+func writeCategoriesFile(filePath string, categories map[int]Category) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(categories))
+	for id := range categories {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, id := range ids {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		data, err := marshalJSON(categories[id])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buffer, "%q:", strconv.Itoa(id))
+		buffer.Write(data)
+	}
+	buffer.WriteByte('}')
+	return writeIndentedJSON(filePath, buffer.Bytes())
+}
+
+// marshalApp marshals an app with the managed fields first,
+// followed by the local fields sorted by name.
+func marshalApp(fingerprint OutputFingerprint) ([]byte, error) {
+	data, err := marshalJSON(fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	if len(fingerprint.Extra) == 0 {
+		return data, nil
+	}
+
+	names := make([]string, 0, len(fingerprint.Extra))
+	for name := range fingerprint.Extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buffer := bytes.NewBuffer(data[:len(data)-1])
+	for i, name := range names {
+		if i > 0 || len(data) > 2 {
+			buffer.WriteByte(',')
+		}
+		key, err := marshalJSON(name)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(fingerprint.Extra[name])
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// marshalJSON marshals a value without escaping HTML characters,
+// which are common in the patterns.
+func marshalJSON(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buffer.Bytes(), "\n"), nil
+}
+
+// writeIndentedJSON pretty prints a JSON document to a file to make git diffs useful
+func writeIndentedJSON(filePath string, data []byte) error {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, indented.Bytes(), 0o644)
+}
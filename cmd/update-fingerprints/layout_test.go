@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeLocalCategories(t *testing.T) {
+	upstream := map[int]Category{1: {Name: "CMS", Priority: 1}}
+	local := map[int]Category{1: {Name: "Old CMS"}, 200: {Name: "Local"}}
+
+	mergeLocalCategories(upstream, local)
+	require.Equal(t, map[int]Category{1: {Name: "CMS", Priority: 1}, 200: {Name: "Local"}}, upstream, "could not merge local categories")
+}
+
+func TestWriteFingerprintFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "002-old.json")
+	require.Nil(t, os.WriteFile(stale, []byte(`{"apps":{}}`), 0o644), "could not write stale file")
+
+	categories := map[int]Category{1: {Name: "CMS"}, miscellaneousCategory: {Name: "Miscellaneous"}}
+	fingerprints := &OutputFingerprints{Apps: map[string]OutputFingerprint{
+		"WordPress": {Cats: []int{1}},
+		"Unknown":   {Cats: []int{300}},
+	}}
+	require.Nil(t, writeFingerprintFiles(dir, fingerprints, categories), "could not write fingerprints files")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.Nil(t, err, "could not list files")
+	require.Equal(t, []string{filepath.Join(dir, "001-cms.json"), filepath.Join(dir, "019-miscellaneous.json")}, files, "could not write files by category")
+	require.NoFileExists(t, stale, "could not remove file of category without apps")
+
+	apps, err := readLocalApps(dir)
+	require.Nil(t, err, "could not read written files")
+	require.Equal(t, []int{300}, apps["Unknown"].Cats, "could not write app of unknown category")
+}
@@ -3,18 +3,27 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"time"
 )
 
-var fingerprints = flag.String("fingerprints", "../../fingerprints_data.json", "File to write wappalyzer fingerprints to")
+var (
+	dataDir      = flag.String("data", "../../data", "Data directory to write the fingerprints/ and categories/ files to")
+	prune        = flag.Bool("prune", false, "Remove the local apps not present upstream")
+	fingerprints = flag.String("fingerprints", "", "File to also write all the fingerprints to, in the legacy single file format")
+	sources      = flag.String("source", strings.Join(upstreamURLs, ","), "Comma separated upstream sources, URLs of src directories, local checkouts or tarballs (later sources override earlier ones)")
+	timeout      = flag.Duration("timeout", 30*time.Second, "Timeout of the HTTP requests to the upstream sources")
+	reportFile   = flag.String("report", "", "Write the report of the changes to a file instead of stdout")
+)
 
 // Fingerprints contains a map of fingerprints for tech detection
 type Fingerprints struct {
@@ -35,6 +44,9 @@ type Fingerprint struct {
 	ScriptSrc   interface{}            `json:"scriptSrc"`
 	Meta        map[string]interface{} `json:"meta"`
 	Implies     interface{}            `json:"implies"`
+	Excludes    interface{}            `json:"excludes"`
+	Requires    interface{}            `json:"requires"`
+	RequiresCat interface{}            `json:"requiresCategory"`
 	Description string                 `json:"description"`
 	Website     string                 `json:"website"`
 	Icon        string                 `json:"icon"`
@@ -52,8 +64,8 @@ type OutputFingerprints struct {
 type OutputFingerprint struct {
 	Cats        []int                             `json:"cats,omitempty"`
 	CSS         []string                          `json:"css,omitempty"`
-	DOM         map[string]map[string]interface{} `json:"dom,omitempty"`
 	Cookies     map[string]string                 `json:"cookies,omitempty"`
+	DOM         map[string]map[string]interface{} `json:"dom,omitempty"`
 	JS          map[string]string                 `json:"js,omitempty"`
 	Headers     map[string]string                 `json:"headers,omitempty"`
 	HTML        []string                          `json:"html,omitempty"`
@@ -61,96 +73,169 @@ type OutputFingerprint struct {
 	ScriptSrc   []string                          `json:"scriptSrc,omitempty"`
 	Meta        map[string][]string               `json:"meta,omitempty"`
 	Implies     []string                          `json:"implies,omitempty"`
+	Excludes    []string                          `json:"excludes,omitempty"`
+	Requires    []string                          `json:"requires,omitempty"`
+	RequiresCat []int                             `json:"requiresCategory,omitempty"`
 	Description string                            `json:"description,omitempty"`
 	Website     string                            `json:"website,omitempty"`
 	CPE         string                            `json:"cpe,omitempty"`
 	Icon        string                            `json:"icon,omitempty"`
-}
 
-var fingerprintURLs = []string{
-	"https://raw.githubusercontent.com/enthec/webappanalyzer/main/src/technologies/%s.json",
-	"https://raw.githubusercontent.com/HTTPArchive/wappalyzer/main/src/technologies/%s.json",
-}
-
-func makeFingerprintURLs() []string {
-	files := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z", "_"}
-
-	fingerprints := make([]string, 0, len(files))
-	for _, item := range files {
-		for _, fingerprintURL := range fingerprintURLs {
-			fingerprints = append(fingerprints, fmt.Sprintf(fingerprintURL, item))
-		}
-	}
-	return fingerprints
+	// Extra contains the fields added locally to the app, such as
+	// browser, which are kept when updating from upstream.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 func main() {
 	flag.Parse()
 
-	fingerprintURLs := makeFingerprintURLs()
+	client := &http.Client{Timeout: *timeout}
+
+	var upstreamSources []upstreamSource
+	for _, location := range strings.Split(*sources, ",") {
+		if location = strings.TrimSpace(location); location == "" {
+			continue
+		}
+		source, err := newUpstreamSource(location, client)
+		if err != nil {
+			log.Fatalf("Could not open source %s: %v\n", location, err)
+		}
+		upstreamSources = append(upstreamSources, source)
+	}
+	if len(upstreamSources) == 0 {
+		log.Fatalf("No upstream sources\n")
+	}
 
 	fingerprintsOld := &Fingerprints{
 		Apps: make(map[string]Fingerprint),
 	}
-	for _, fingerprintItem := range fingerprintURLs {
-		if err := gatherFingerprintsFromURL(fingerprintItem, fingerprintsOld); err != nil {
-			log.Fatalf("Could not gather fingerprints %s: %v\n", fingerprintItem, err)
+	for _, source := range upstreamSources {
+		if err := gatherFingerprints(source, fingerprintsOld); err != nil {
+			log.Fatalf("Could not gather fingerprints %s: %v\n", source, err)
 		}
 	}
 
-	log.Printf("Read fingerprints from the server\n")
+	log.Printf("Read fingerprints from %d sources\n", len(upstreamSources))
 	log.Printf("Starting normalizing of %d fingerprints...\n", len(fingerprintsOld.Apps))
 
 	outputFingerprints := normalizeFingerprints(fingerprintsOld)
 
 	log.Printf("Got %d valid fingerprints\n", len(outputFingerprints.Apps))
 
-	fingerprintsFile, err := os.OpenFile(*fingerprints, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	categoriesFile := filepath.Join(*dataDir, "categories", "categories.json")
+	localCategories, err := readLocalCategories(categoriesFile)
 	if err != nil {
-		log.Fatalf("Could not open fingerprints file %s: %s\n", *fingerprints, err)
+		log.Fatalf("Could not read local categories: %s\n", err)
 	}
 
-	// sort map keys and pretty print the json to make git diffs useful
+	categories := make(map[int]Category)
+	for _, source := range upstreamSources {
+		if err := gatherCategories(source, categories); err != nil {
+			log.Fatalf("Could not gather categories %s: %v\n", source, err)
+		}
+	}
+	mergeLocalCategories(categories, localCategories)
+	log.Printf("Got %d categories\n", len(categories))
 
-	data, err := json.MarshalIndent(outputFingerprints, "", "    ")
+	fingerprintsDir := filepath.Join(*dataDir, "fingerprints")
+	localApps, err := readLocalApps(fingerprintsDir)
 	if err != nil {
-		log.Fatalf("Could not marshal fingerprints: %s\n", err)
+		log.Fatalf("Could not read local fingerprints: %s\n", err)
 	}
-	_, err = fingerprintsFile.Write(data)
-	if err != nil {
-		log.Fatalf("Could not write fingerprints file: %s\n", err)
+	mergeLocalApps(outputFingerprints, localApps, *prune)
+
+	if err := writeReport(diffFingerprints(localApps, outputFingerprints.Apps, localCategories, categories)); err != nil {
+		log.Fatalf("Could not write report: %s\n", err)
 	}
-	err = fingerprintsFile.Close()
-	if err != nil {
-		log.Fatalf("Could not close fingerprints file: %s\n", err)
+
+	if err := writeCategoriesFile(categoriesFile, categories); err != nil {
+		log.Fatalf("Could not write categories file: %s\n", err)
+	}
+	if err := writeFingerprintFiles(fingerprintsDir, outputFingerprints, categories); err != nil {
+		log.Fatalf("Could not write fingerprints files: %s\n", err)
+	}
+
+	if *fingerprints != "" {
+		if err := writeLegacyFingerprintsFile(*fingerprints, outputFingerprints); err != nil {
+			log.Fatalf("Could not write fingerprints file %s: %s\n", *fingerprints, err)
+		}
 	}
 }
 
-func gatherFingerprintsFromURL(URL string, fingerprints *Fingerprints) error {
-	req, err := http.NewRequest(http.MethodGet, URL, nil)
+// writeLegacyFingerprintsFile writes all the fingerprints to a single file
+func writeLegacyFingerprintsFile(filePath string, outputFingerprints *OutputFingerprints) error {
+	fingerprintsFile, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	// sort map keys and pretty print the json to make git diffs useful
+
+	data, err := json.MarshalIndent(outputFingerprints, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fingerprintsFile.Write(data)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	return fingerprintsFile.Close()
+}
+
+// writeReport writes the report of the changes to the report file or stdout
+func writeReport(report *diffReport) error {
+	if *reportFile == "" {
+		report.write(os.Stdout)
+		return nil
+	}
 
-	data, err := io.ReadAll(resp.Body)
+	file, err := os.Create(*reportFile)
 	if err != nil {
 		return err
 	}
+	report.write(file)
+	return file.Close()
+}
 
-	fingerprintsOld := &Fingerprints{}
-	err = json.NewDecoder(bytes.NewReader(data)).Decode(&fingerprintsOld.Apps)
+// gatherCategories reads the categories of a source, if any
+func gatherCategories(source upstreamSource, categories map[int]Category) error {
+	data, err := source.readFile("categories.json")
+	if errors.Is(err, errSourceFileNotFound) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
+	return parseCategories(data, categories)
+}
 
-	for k, v := range fingerprintsOld.Apps {
-		fingerprints.Apps[k] = v
+// gatherFingerprints reads the technologies files of a source.
+// Missing files are skipped, but a source must have at least one.
+func gatherFingerprints(source upstreamSource, fingerprints *Fingerprints) error {
+	var found int
+	for _, item := range technologiesFiles {
+		name := "technologies/" + item + ".json"
+		data, err := source.readFile(name)
+		if errors.Is(err, errSourceFileNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", name, err)
+		}
+
+		fingerprintsOld := &Fingerprints{}
+		err = json.NewDecoder(bytes.NewReader(data)).Decode(&fingerprintsOld.Apps)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", name, err)
+		}
+
+		for k, v := range fingerprintsOld.Apps {
+			fingerprints.Apps[k] = v
+		}
+		found++
+	}
+	if found == 0 {
+		return errors.New("no technologies files found")
 	}
 	return nil
 }
@@ -306,6 +391,10 @@ func normalizeFingerprints(fingerprints *Fingerprints) *OutputFingerprints {
 			sort.Strings(output.Implies)
 		}
 
+		output.Excludes = normalizeStringList(fingerprint.Excludes)
+		output.Requires = normalizeStringList(fingerprint.Requires)
+		output.RequiresCat = normalizeIntList(fingerprint.RequiresCat)
+
 		// Use reflection type switch for determining CSS tag type
 		if fingerprint.CSS != nil {
 			v := reflect.ValueOf(fingerprint.CSS)
@@ -330,3 +419,41 @@ func normalizeFingerprints(fingerprints *Fingerprints) *OutputFingerprints {
 	}
 	return outputFingerprints
 }
+
+// normalizeStringList normalizes a field that can either be a
+// single string or a list of strings into a sorted list.
+func normalizeStringList(value interface{}) []string {
+	var output []string
+
+	switch v := value.(type) {
+	case string:
+		output = append(output, v)
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				output = append(output, str)
+			}
+		}
+	}
+	sort.Strings(output)
+	return output
+}
+
+// normalizeIntList normalizes a field that can either be a
+// single number or a list of numbers into a sorted list.
+func normalizeIntList(value interface{}) []int {
+	var output []int
+
+	switch v := value.(type) {
+	case float64:
+		output = append(output, int(v))
+	case []interface{}:
+		for _, item := range v {
+			if number, ok := item.(float64); ok {
+				output = append(output, int(number))
+			}
+		}
+	}
+	sort.Ints(output)
+	return output
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// upstreamURLs are the src directories of the upstream repositories.
+// Fingerprints of later repositories override the earlier ones.
+var upstreamURLs = []string{
+	"https://raw.githubusercontent.com/enthec/webappanalyzer/main/src",
+	"https://raw.githubusercontent.com/HTTPArchive/wappalyzer/main/src",
+}
+
+// technologiesFiles are the names of the files of the technologies directory
+var technologiesFiles = []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z", "_"}
+
+// errSourceFileNotFound is returned when a file is not present in a source
+var errSourceFileNotFound = errors.New("file not found")
+
+// upstreamSource is a copy of the src directory of an upstream repository
+type upstreamSource interface {
+	// readFile reads a file relative to the src directory,
+	// such as technologies/a.json or categories.json.
+	readFile(name string) ([]byte, error)
+	// String returns the location of the source
+	String() string
+}
+
+// newUpstreamSource returns the source of a location, an URL of a src
+// directory, a local checkout or a tarball of the upstream repository.
+func newUpstreamSource(location string, client *http.Client) (upstreamSource, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return &urlSource{base: strings.TrimSuffix(location, "/"), client: client}, nil
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return newDirectorySource(location)
+	}
+	return newTarballSource(location)
+}
+
+// urlSource reads the files over HTTP
+type urlSource struct {
+	base   string
+	client *http.Client
+}
+
+func (s *urlSource) readFile(name string) ([]byte, error) {
+	resp, err := s.client.Get(s.base + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errSourceFileNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *urlSource) String() string {
+	return s.base
+}
+
+// directorySource reads the files of a local checkout
+type directorySource struct {
+	dir string
+}
+
+// newDirectorySource returns the source of a local checkout, either the
+// repository, its src directory or its src/technologies directory.
+func newDirectorySource(dir string) (*directorySource, error) {
+	candidates := []string{filepath.Join(dir, "src"), dir}
+	if filepath.Base(filepath.Clean(dir)) == "technologies" {
+		candidates = append(candidates, filepath.Dir(filepath.Clean(dir)))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(filepath.Join(candidate, "technologies")); err == nil && info.IsDir() {
+			return &directorySource{dir: candidate}, nil
+		}
+	}
+	return nil, fmt.Errorf("no technologies directory found in %s", dir)
+}
+
+func (s *directorySource) readFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(name)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errSourceFileNotFound
+	}
+	return data, err
+}
+
+func (s *directorySource) String() string {
+	return s.dir
+}
+
+// tarballSource reads the files of a tarball of the repository,
+// such as the archives downloaded from GitHub.
+type tarballSource struct {
+	path  string
+	files map[string][]byte
+}
+
+// newTarballSource reads the technologies and categories files of a
+// tar archive, gzip compressed or not. The src directory is the parent
+// of the technologies directory of the archive. If there are several,
+// the one in a src directory is used, and the archive is rejected if
+// this is still ambiguous.
+func newTarballSource(tarballPath string) (*tarballSource, error) {
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(tarballPath, ".gz") || strings.HasSuffix(tarballPath, ".tgz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	// Collect all the JSON files, the src directory is only known
+	// once all the technologies directories have been found.
+	entries := make(map[string][]byte)
+	srcDirs := make(map[string]struct{})
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", tarballPath, err)
+		}
+		if header.Typeflag != tar.TypeReg || path.Ext(header.Name) != ".json" {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if path.Base(path.Dir(name)) == "technologies" {
+			srcDirs[path.Dir(path.Dir(name))] = struct{}{}
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s in %s: %w", header.Name, tarballPath, err)
+		}
+		entries[name] = data
+	}
+	srcDir, err := selectSrcDir(srcDirs)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, tarballPath)
+	}
+
+	source := &tarballSource{path: tarballPath, files: make(map[string][]byte)}
+	for name, data := range entries {
+		relative := name
+		if srcDir != "." {
+			if !strings.HasPrefix(name, srcDir+"/") {
+				continue
+			}
+			relative = strings.TrimPrefix(name, srcDir+"/")
+		}
+		source.files[relative] = data
+	}
+	return source, nil
+}
+
+// selectSrcDir selects the src directory among the parents of the
+// technologies directories of an archive.
+func selectSrcDir(srcDirs map[string]struct{}) (string, error) {
+	candidates := make([]string, 0, len(srcDirs))
+	for dir := range srcDirs {
+		candidates = append(candidates, dir)
+	}
+	sort.Strings(candidates)
+
+	if len(candidates) > 1 {
+		var inSrc []string
+		for _, dir := range candidates {
+			if path.Base(dir) == "src" {
+				inSrc = append(inSrc, dir)
+			}
+		}
+		candidates = inSrc
+	}
+	switch len(candidates) {
+	case 0:
+		if len(srcDirs) == 0 {
+			return "", errors.New("no technologies directory found")
+		}
+		return "", fmt.Errorf("several technologies directories found, none in a src directory: %s", strings.Join(sortedKeys(srcDirs), ", "))
+	case 1:
+		return candidates[0], nil
+	}
+	return "", fmt.Errorf("several src/technologies directories found: %s", strings.Join(candidates, ", "))
+}
+
+func (s *tarballSource) readFile(name string) ([]byte, error) {
+	data, ok := s.files[name]
+	if !ok {
+		return nil, errSourceFileNotFound
+	}
+	return data, nil
+}
+
+func (s *tarballSource) String() string {
+	return s.path
+}
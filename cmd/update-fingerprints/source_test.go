@@ -0,0 +1,123 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeTarball writes files to a gzip compressed tarball
+func writeTarball(t *testing.T, files map[string]string) string {
+	tarballPath := filepath.Join(t.TempDir(), "upstream.tar.gz")
+	file, err := os.Create(tarballPath)
+	require.Nil(t, err, "could not create tarball")
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		require.Nil(t, err, "could not write tarball header")
+		_, err = tarWriter.Write([]byte(content))
+		require.Nil(t, err, "could not write tarball file")
+	}
+	require.Nil(t, tarWriter.Close(), "could not close tarball")
+	require.Nil(t, gzipWriter.Close(), "could not close gzip")
+	return tarballPath
+}
+
+func TestTarballSource(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// technologies is the content of technologies/a.json, empty if the tarball is rejected
+		technologies string
+	}{
+		{
+			name:         "github archive",
+			files:        map[string]string{"repo-main/src/technologies/a.json": `src`, "repo-main/src/categories.json": `{}`},
+			technologies: `src`,
+		},
+		{
+			name:         "root",
+			files:        map[string]string{"./technologies/a.json": `root`},
+			technologies: `root`,
+		},
+		{
+			name: "prefer src",
+			files: map[string]string{
+				"repo-main/build/technologies/a.json": `build`,
+				"repo-main/src/technologies/a.json":   `src`,
+			},
+			technologies: `src`,
+		},
+		{
+			name: "ambiguous",
+			files: map[string]string{
+				"repo-main/a/src/technologies/a.json": `a`,
+				"repo-main/b/src/technologies/a.json": `b`,
+			},
+		},
+		{
+			name: "ambiguous without src",
+			files: map[string]string{
+				"repo-main/a/technologies/a.json": `a`,
+				"repo-main/b/technologies/a.json": `b`,
+			},
+		},
+		{
+			name:  "missing",
+			files: map[string]string{"repo-main/README.json": `{}`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := newTarballSource(writeTarball(t, test.files))
+			if test.technologies == "" {
+				require.NotNil(t, err, "could open invalid tarball")
+				return
+			}
+			require.Nil(t, err, "could not open tarball")
+
+			data, err := source.readFile("technologies/a.json")
+			require.Nil(t, err, "could not read technologies file")
+			require.Equal(t, test.technologies, string(data), "could not select src directory")
+		})
+	}
+}
+
+func TestDirectorySource(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	require.Nil(t, os.MkdirAll(filepath.Join(src, "technologies"), 0o755), "could not create directories")
+	require.Nil(t, os.WriteFile(filepath.Join(src, "technologies", "a.json"), []byte(`{}`), 0o644), "could not write technologies file")
+
+	tests := []struct {
+		name string
+		dir  string
+		err  bool
+	}{
+		{name: "repository", dir: root},
+		{name: "src", dir: src},
+		{name: "technologies", dir: filepath.Join(src, "technologies")},
+		{name: "missing", dir: t.TempDir(), err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := newDirectorySource(test.dir)
+			if test.err {
+				require.NotNil(t, err, "could open directory without technologies")
+				return
+			}
+			require.Nil(t, err, "could not open directory")
+			require.Equal(t, src, source.dir, "could not resolve src directory")
+
+			_, err = source.readFile("categories.json")
+			require.ErrorIs(t, err, errSourceFileNotFound, "could not get missing file error")
+		})
+	}
+}
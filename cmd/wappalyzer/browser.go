@@ -1 +1,23 @@
 package main
+
+import (
+	browserutil "github.com/projectdiscovery/wappalyzergo/internal/browser"
+)
+
+// browserPool is the browser shared by all the scans, nil in static mode
+var browserPool *browserutil.Pool
+
+// newBrowserPool creates the browser pool used by the scans, with one tab per concurrent scan
+func newBrowserPool() *browserutil.Pool {
+	return browserutil.NewPool(browserutil.PoolOptions{
+		Headless:       *headless,
+		UserAgent:      *userAgent,
+		MaxTabs:        *concurrency,
+		MaxPagesPerTab: *tabPages,
+	})
+}
+
+// newBrowserDetector creates a browser detector using the shared browser pool
+func newBrowserDetector() *browserutil.Detector {
+	return browserutil.NewDetector(browserPool, *waitTime)
+}
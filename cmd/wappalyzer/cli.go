@@ -10,11 +10,13 @@ import (
 // CLI flags - all flag variables are defined here for easy management
 var (
 	// Output flags
-	jsonOutput = flag.Bool("json", false, "Output results as JSON (deprecated, use -format json)")
-	detailed   = flag.Bool("detailed", false, "Show detailed information including categories and descriptions")
-	format     = flag.String("format", "text", "Output format: text, json, jsonl")
-	outputFile = flag.String("o", "", "Write output to file instead of stdout")
-	silent     = flag.Bool("silent", false, "Silent mode, only output results")
+	jsonOutput    = flag.Bool("json", false, "Output results as JSON (deprecated, use -format json)")
+	detailed      = flag.Bool("detailed", false, "Show detailed information including categories and descriptions")
+	format        = flag.String("format", "text", "Output format: text, json, jsonl")
+	outputFile    = flag.String("o", "", "Write output to file instead of stdout")
+	silent        = flag.Bool("silent", false, "Silent mode, only output results")
+	evidence      = flag.Bool("evidence", false, "Include the rules that matched for each technology")
+	minConfidence = flag.Int("min-confidence", 0, "Minimum confidence (0-100) for a technology to be reported")
 
 	// Detection mode flags
 	staticMode = flag.Bool("static", false, "Use static HTTP mode (no JavaScript execution)")
@@ -28,7 +30,10 @@ var (
 	listFile = flag.String("l", "", "Read URLs from file (one per line)")
 
 	// Performance flags
-	concurrency = flag.Int("c", 1, "Number of concurrent requests")
+	concurrency    = flag.Int("c", 1, "Number of concurrent requests")
+	maxScriptBytes = flag.Int("max-script-bytes", 0, "Maximum inline script bytes scanned per page (0 for no limit)")
+	cacheFile      = flag.String("cache", "", "Cache the loaded fingerprints in a file to speed up later startups")
+	tabPages       = flag.Int("tab-pages", 20, "Number of pages loaded in a browser tab before it is replaced by a new one")
 
 	// Configuration flags
 	userAgent       = flag.String("user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0.0.0", "Custom User-Agent header")
@@ -43,7 +48,9 @@ const version = "2.0.0"
 // printUsage prints the command usage information
 func printUsage() {
 	fmt.Fprintf(os.Stderr, "Wappalyzer CLI v%s - Detect web technologies\n\n", version)
-	fmt.Fprintf(os.Stderr, "Usage: %s [options] <url> [url2] [url3]...\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [options] <url> [url2] [url3]...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s diagnostics [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s lint [options] [fingerprints-dir]\n\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "By default, uses headless browser for accurate JavaScript-based detection.\n\n")
 	fmt.Fprintf(os.Stderr, "Options:\n")
 	flag.PrintDefaults()
@@ -53,4 +60,6 @@ func printUsage() {
 	fmt.Fprintf(os.Stderr, "  %s -detailed https://nextjs.org/\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s -static https://nextjs.org/  # Fast mode, no JS\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  %s -c 5 -l urls.txt  # Concurrent scanning\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s diagnostics  # List invalid fingerprint rules\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s lint ./data/fingerprints  # Check fingerprints files\n", os.Args[0])
 }
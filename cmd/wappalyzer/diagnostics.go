@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	wappalyzer "github.com/projectdiscovery/wappalyzergo"
+)
+
+// runDiagnostics runs the diagnostics subcommand, printing the fingerprint
+// rules that could not be compiled. It returns the process exit code,
+// 1 if any rule could not be compiled.
+func runDiagnostics(args []string) int {
+	flags := flag.NewFlagSet("diagnostics", flag.ExitOnError)
+	dir := flags.String("fingerprints-dir", "", "Check fingerprints from directory instead of embedded data (e.g., ./data/fingerprints)")
+	outputFormat := flags.String("format", "text", "Output format: text, json")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s diagnostics [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Prints the fingerprint rules that could not be compiled and are ignored,\nexiting with status 1 if there are any.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	var wappalyzerClient *wappalyzer.Wappalyze
+	var err error
+	if *dir != "" {
+		wappalyzerClient, err = wappalyzer.NewFromDirectory(*dir)
+	} else {
+		wappalyzerClient, err = wappalyzer.New()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing wappalyzer: %v\n", err)
+		return 1
+	}
+
+	diagnostics := wappalyzerClient.LoadReport().Diagnostics
+	switch OutputFormat(*outputFormat) {
+	case FormatJSON:
+		if diagnostics == nil {
+			diagnostics = []wappalyzer.Diagnostic{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diagnostics); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	case FormatText:
+		for _, diagnostic := range diagnostics {
+			fmt.Println(diagnostic.String())
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: invalid format %q (valid: text, json)\n", *outputFormat)
+		return 1
+	}
+
+	apps := make(map[string]struct{})
+	for _, diagnostic := range diagnostics {
+		apps[diagnostic.App] = struct{}{}
+	}
+	if len(diagnostics) > 0 {
+		fmt.Fprintf(os.Stderr, "[ERROR] Found %d invalid rules in %d apps\n", len(diagnostics), len(apps))
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "[INFO] No invalid rules found")
+	return 0
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunDiagnostics(t *testing.T) {
+	tests := []struct {
+		name     string
+		apps     string
+		exitCode int
+	}{
+		{name: "valid", apps: `{"apps": {"App": {"headers": {"server": "app"}}}}`, exitCode: 0},
+		{name: "invalid", apps: `{"apps": {"App": {"headers": {"server": "app(?<=a)"}}}}`, exitCode: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.Nil(t, os.WriteFile(filepath.Join(dir, "001-app.json"), []byte(test.apps), 0o644), "could not write fingerprints")
+
+			require.Equal(t, test.exitCode, runDiagnostics([]string{"-fingerprints-dir", dir, "-format", "json"}), "could not get correct exit code")
+		})
+	}
+}
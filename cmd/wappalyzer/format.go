@@ -7,41 +7,63 @@ import (
 	wappalyzer "github.com/projectdiscovery/wappalyzergo"
 )
 
-// formatSimpleFingerprints converts fingerprint map to simple tech->version map
-func formatSimpleFingerprints(fingerprints map[string]struct{}) map[string]string {
-	result := make(map[string]string)
-	for tech := range fingerprints {
-		parts := strings.Split(tech, ":")
-		if len(parts) == 2 {
-			result[parts[0]] = parts[1]
-		} else {
-			result[tech] = ""
+// formatSimpleFingerprints converts detections to simple tech->version map
+func formatSimpleFingerprints(detections []wappalyzer.Detection) map[string]string {
+	result := make(map[string]string, len(detections))
+	for _, detection := range detections {
+		result[detection.Name] = detection.Version
+	}
+	return result
+}
+
+// formatDetailedFingerprints converts detections to detailed result
+func formatDetailedFingerprints(detections []wappalyzer.Detection) map[string]TechnologyDetails {
+	result := make(map[string]TechnologyDetails, len(detections))
+	for _, detection := range detections {
+		result[detection.Name] = TechnologyDetails{
+			Version:     detection.Version,
+			Confidence:  detection.Confidence,
+			Categories:  detection.Categories,
+			Description: detection.Description,
+			Website:     detection.Website,
+			Evidence:    detection.Evidence,
 		}
 	}
 	return result
 }
 
-// formatDetailedFingerprints converts fingerprint map with info to detailed result
-func formatDetailedFingerprints(fingerprints map[string]wappalyzer.AppInfo) map[string]TechnologyDetails {
-	result := make(map[string]TechnologyDetails)
-	for tech, info := range fingerprints {
-		parts := strings.Split(tech, ":")
-		name := parts[0]
-		version := ""
-		if len(parts) == 2 {
-			version = parts[1]
+// formatConfidenceMap converts detections to a tech->confidence map,
+// only including technologies detected with less than full confidence.
+func formatConfidenceMap(detections []wappalyzer.Detection) map[string]int {
+	result := make(map[string]int)
+	for _, detection := range detections {
+		if detection.Confidence < 100 {
+			result[detection.Name] = detection.Confidence
 		}
+	}
+	return result
+}
 
-		result[name] = TechnologyDetails{
-			Version:     version,
-			Categories:  info.Categories,
-			Description: info.Description,
-			Website:     info.Website,
+// formatEvidenceMap converts detections to a tech->evidence map
+func formatEvidenceMap(detections []wappalyzer.Detection) map[string][]wappalyzer.Evidence {
+	result := make(map[string][]wappalyzer.Evidence)
+	for _, detection := range detections {
+		if len(detection.Evidence) > 0 {
+			result[detection.Name] = detection.Evidence
 		}
 	}
 	return result
 }
 
+// formatEvidence formats a single evidence item as a line of text
+func formatEvidence(item wappalyzer.Evidence) string {
+	part := item.Part
+	if item.Key != "" {
+		part = fmt.Sprintf("%s[%s]", item.Part, item.Key)
+	}
+	return fmt.Sprintf("%s pattern=%q match=%q confidence=%d", part, item.Pattern, item.Match, item.Confidence)
+}
+
 // printSimpleResult prints technologies in simple format
 func printSimpleResult(url string, technologies map[string]string, mode string) {
 	fmt.Printf("\n%s [%s]\n", url, mode)
@@ -76,6 +98,10 @@ func printDetailedResult(url string, technologies map[string]TechnologyDetails,
 		}
 		fmt.Println()
 
+		if details.Confidence > 0 && details.Confidence < 100 {
+			fmt.Printf("    Confidence: %d%%\n", details.Confidence)
+		}
+
 		if len(details.Categories) > 0 {
 			fmt.Printf("    Categories: %s\n", strings.Join(details.Categories, ", "))
 		}
@@ -92,5 +118,9 @@ func printDetailedResult(url string, technologies map[string]TechnologyDetails,
 			}
 			fmt.Printf("    Description: %s\n", desc)
 		}
+
+		for _, item := range details.Evidence {
+			fmt.Printf("    Evidence: %s\n", formatEvidence(item))
+		}
 	}
 }
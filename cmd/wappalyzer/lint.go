@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	wappalyzer "github.com/projectdiscovery/wappalyzergo"
+)
+
+// fingerprintFileRegex matches the names of the fingerprints files
+var fingerprintFileRegex = regexp.MustCompile(`^\d{3}-[a-z0-9-]+\.json$`)
+
+// knownDetectionTypes and knownVersionTypes are the browser rule types
+// supported by the browser detection, with their required fields.
+var (
+	knownDetectionTypes = map[string][]string{
+		"dom-selector": {"selector"},
+		"js-eval":      {"query"},
+	}
+	knownVersionTypes = map[string][]string{
+		"dom-attribute": {"selector", "attribute"},
+		"js-eval":       {"query"},
+	}
+)
+
+// lintIssue is a problem found in a fingerprints file
+type lintIssue struct {
+	File    string
+	App     string
+	Message string
+}
+
+func (i lintIssue) String() string {
+	if i.App == "" {
+		return fmt.Sprintf("%s: %s", i.File, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.File, i.App, i.Message)
+}
+
+// linter checks the fingerprints files of a directory
+type linter struct {
+	dir        string
+	categories map[int]struct{}
+	iconsDir   string
+
+	issues []lintIssue
+	// apps contains the fingerprints of every app
+	apps map[string]*wappalyzer.Fingerprint
+	// files contains the files defining every app
+	files map[string][]string
+}
+
+// runLint runs the lint subcommand, checking a fingerprints directory.
+// It returns the process exit code.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	categoriesFile := flags.String("categories", "", "Categories file (default: ../categories/categories.json next to the directory, or the embedded categories)")
+	iconsDir := flags.String("icons-dir", "", "Directory of the icons referenced by the fingerprints (default: ../icons next to the directory, icons are not checked if missing)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s lint [options] [fingerprints-dir]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Checks the NNN-category.json fingerprints files of a directory (default: data/fingerprints).\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	dir := "data/fingerprints"
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+
+	categories, err := loadLintCategories(dir, *categoriesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	icons := lintIconsDir(dir, *iconsDir)
+	if icons == "" {
+		fmt.Fprintf(os.Stderr, "[WARN] Icons not checked, no icons directory next to %s (use -icons-dir)\n", dir)
+	}
+
+	l := newLinter(dir, categories, icons)
+	if err := l.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	for _, issue := range l.issues {
+		fmt.Println(issue.String())
+	}
+	if len(l.issues) > 0 {
+		fmt.Fprintf(os.Stderr, "[ERROR] Found %d issues in %d apps checked\n", len(l.issues), len(l.files))
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "[INFO] No issues found in %d apps\n", len(l.files))
+	return 0
+}
+
+// newLinter creates a linter for a fingerprints directory. Icons are
+// not checked if iconsDir is empty.
+func newLinter(dir string, categories map[int]struct{}, iconsDir string) *linter {
+	return &linter{
+		dir:        dir,
+		categories: categories,
+		iconsDir:   iconsDir,
+		apps:       make(map[string]*wappalyzer.Fingerprint),
+		files:      make(map[string][]string),
+	}
+}
+
+// lintIconsDir returns the icons directory, the icons directory of the
+// data directory if not set, or an empty string if there is none.
+func lintIconsDir(dir, iconsDir string) string {
+	if iconsDir != "" {
+		return iconsDir
+	}
+	candidate := filepath.Join(dir, "..", "icons")
+	if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+		return candidate
+	}
+	return ""
+}
+
+// loadLintCategories loads the category IDs from the categories file,
+// the categories file of the data directory or the embedded categories.
+func loadLintCategories(dir, categoriesFile string) (map[int]struct{}, error) {
+	if categoriesFile == "" {
+		candidate := filepath.Join(dir, "..", "categories", "categories.json")
+		if _, err := os.Stat(candidate); err == nil {
+			categoriesFile = candidate
+		}
+	}
+
+	categories := make(map[int]struct{})
+	if categoriesFile == "" {
+		for id := range wappalyzer.GetCategoriesMapping() {
+			categories[id] = struct{}{}
+		}
+		return categories, nil
+	}
+
+	data, err := os.ReadFile(categoriesFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read categories: %w", err)
+	}
+	var parsed map[string]json.RawMessage
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse categories %s: %w", categoriesFile, err)
+	}
+	for key := range parsed {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category ID %q in %s", key, categoriesFile)
+		}
+		categories[id] = struct{}{}
+	}
+	return categories, nil
+}
+
+// run checks all the fingerprints files of the directory
+func (l *linter) run() error {
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no fingerprint files found in %s", l.dir)
+	}
+
+	for _, file := range files {
+		if err := l.checkFile(file); err != nil {
+			return err
+		}
+	}
+	l.checkDuplicates()
+	l.checkPatterns()
+	for _, app := range sortedApps(l.apps) {
+		l.checkApp(app, l.apps[app])
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		if l.issues[i].File != l.issues[j].File {
+			return l.issues[i].File < l.issues[j].File
+		}
+		return l.issues[i].App < l.issues[j].App
+	})
+	return nil
+}
+
+func (l *linter) addIssue(file, app, format string, args ...interface{}) {
+	l.issues = append(l.issues, lintIssue{File: file, App: app, Message: fmt.Sprintf(format, args...)})
+}
+
+// appFile returns the file defining an app, the last one if duplicated
+func (l *linter) appFile(app string) string {
+	files := l.files[app]
+	return files[len(files)-1]
+}
+
+// checkFile checks the schema of a fingerprints file and collects its apps
+func (l *linter) checkFile(file string) error {
+	name := filepath.Base(file)
+	if !fingerprintFileRegex.MatchString(name) {
+		l.addIssue(name, "", "file name does not follow the NNN-category.json layout")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err != nil {
+		l.addIssue(name, "", "invalid JSON: %v", err)
+		return nil
+	}
+	for key := range document {
+		if key != "apps" {
+			l.addIssue(name, "", "unknown top-level field %q", key)
+		}
+	}
+
+	var apps map[string]json.RawMessage
+	if err := json.Unmarshal(document["apps"], &apps); err != nil || apps == nil {
+		l.addIssue(name, "", "missing or invalid apps object")
+		return nil
+	}
+
+	for app, raw := range apps {
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+
+		fingerprint := &wappalyzer.Fingerprint{}
+		if err := decoder.Decode(fingerprint); err != nil {
+			l.addIssue(name, app, "invalid fingerprint: %v", err)
+			continue
+		}
+		l.apps[app] = fingerprint
+		l.files[app] = append(l.files[app], name)
+	}
+	return nil
+}
+
+// checkDuplicates checks that no app is defined in several files
+func (l *linter) checkDuplicates() {
+	for _, app := range sortedApps(l.apps) {
+		if files := l.files[app]; len(files) > 1 {
+			l.addIssue(l.appFile(app), app, "app is defined in several files: %s", strings.Join(files, ", "))
+		}
+	}
+}
+
+// checkPatterns checks that all the patterns compile, using the
+// diagnostics of the library.
+func (l *linter) checkPatterns() {
+	loader := wappalyzer.NewLoader(wappalyzer.MemorySource(l.dir, &wappalyzer.Fingerprints{Apps: l.apps}))
+	client, err := wappalyzer.NewFromLoader(loader)
+	if err != nil {
+		l.addIssue(l.dir, "", "could not load fingerprints: %v", err)
+		return
+	}
+	for _, diagnostic := range client.LoadReport().Diagnostics {
+		location := ""
+		if diagnostic.Key != "" {
+			location = " (" + diagnostic.Key + ")"
+		}
+		l.addIssue(l.appFile(diagnostic.App), diagnostic.App, "invalid %s pattern %q%s: %s", diagnostic.Part, diagnostic.Pattern, location, diagnostic.Message)
+	}
+}
+
+// checkApp checks the references of an app
+func (l *linter) checkApp(app string, fingerprint *wappalyzer.Fingerprint) {
+	file := l.appFile(app)
+
+	if len(fingerprint.Cats) == 0 {
+		l.addIssue(file, app, "no categories")
+	}
+	for _, cat := range fingerprint.Cats {
+		if _, ok := l.categories[cat]; !ok {
+			l.addIssue(file, app, "unknown category %d", cat)
+		}
+	}
+	for _, cat := range fingerprint.RequiresCategory {
+		if _, ok := l.categories[cat]; !ok {
+			l.addIssue(file, app, "unknown required category %d", cat)
+		}
+	}
+
+	for _, references := range []struct {
+		field   string
+		targets []string
+	}{
+		{"implies", fingerprint.Implies},
+		{"requires", fingerprint.Requires},
+		{"excludes", fingerprint.Excludes},
+	} {
+		for _, target := range references.targets {
+			name := strings.TrimSpace(strings.Split(target, "\\;")[0])
+			if _, ok := l.apps[name]; !ok {
+				l.addIssue(file, app, "%s unknown app %q", references.field, name)
+			}
+		}
+	}
+
+	if browser := fingerprint.Browser; browser != nil {
+		for i, rule := range browser.Detection {
+			l.checkBrowserRule(file, app, fmt.Sprintf("browser detection rule %d", i), knownDetectionTypes, rule.Type, map[string]string{
+				"selector": rule.Selector,
+				"query":    rule.Query,
+			})
+		}
+		for i, rule := range browser.Version {
+			l.checkBrowserRule(file, app, fmt.Sprintf("browser version rule %d", i), knownVersionTypes, rule.Type, map[string]string{
+				"selector":  rule.Selector,
+				"query":     rule.Query,
+				"attribute": rule.Attribute,
+			})
+			if rule.Pattern != "" {
+				if _, err := regexp.Compile(rule.Pattern); err != nil {
+					l.addIssue(file, app, "browser version rule %d has an invalid pattern: %v", i, err)
+				}
+			}
+		}
+	}
+
+	if l.iconsDir != "" && fingerprint.Icon != "" {
+		if _, err := os.Stat(filepath.Join(l.iconsDir, fingerprint.Icon)); err != nil {
+			l.addIssue(file, app, "icon %q not found in %s", fingerprint.Icon, l.iconsDir)
+		}
+	}
+}
+
+// checkBrowserRule checks the type and the required fields of a browser rule
+func (l *linter) checkBrowserRule(file, app, rule string, known map[string][]string, ruleType string, fields map[string]string) {
+	required, ok := known[ruleType]
+	if !ok {
+		l.addIssue(file, app, "%s has an unknown type %q", rule, ruleType)
+		return
+	}
+	for _, field := range required {
+		if fields[field] == "" {
+			l.addIssue(file, app, "%s of type %s is missing %s", rule, ruleType, field)
+		}
+	}
+}
+
+// sortedApps returns the app names in sorted order
+func sortedApps(apps map[string]*wappalyzer.Fingerprint) []string {
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
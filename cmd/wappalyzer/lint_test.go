@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	dir := filepath.Join("testdata", "lint", "fingerprints")
+	categories, err := loadLintCategories(dir, "")
+	require.Nil(t, err, "could not load categories")
+	require.Equal(t, map[int]struct{}{1: {}, 2: {}}, categories, "could not load categories next to the directory")
+
+	iconsDir := lintIconsDir(dir, "")
+	require.Equal(t, filepath.Join(dir, "..", "icons"), iconsDir, "could not find icons next to the directory")
+	require.Empty(t, lintIconsDir(t.TempDir(), ""), "could find icons directory")
+
+	l := newLinter(dir, categories, iconsDir)
+	require.Nil(t, l.run(), "could not lint directory")
+
+	// Issues ending with ": " are checked up to the error message of
+	// the regexp and encoding/json packages
+	expected := []string{
+		`001-cms.json: Drupal: unknown category 99`,
+		`001-cms.json: Drupal: unknown required category 98`,
+		`001-cms.json: Drupal: implies unknown app "Missing Library"`,
+		`001-cms.json: Drupal: requires unknown app "Missing Runtime"`,
+		`001-cms.json: Drupal: excludes unknown app "Missing CMS"`,
+		`001-cms.json: Drupal: icon "Drupal.svg" not found in ` + iconsDir,
+		`001-cms.json: Typo: invalid fingerprint: json: unknown field "header"`,
+		`002-programming-languages.json: Duplicated: app is defined in several files: 001-cms.json, 002-programming-languages.json`,
+		`002-programming-languages.json: PHP: invalid header pattern "php(?<=a)" (x-powered-by): `,
+		`002-programming-languages.json: Scripted: browser detection rule 1 has an unknown type "xpath"`,
+		`002-programming-languages.json: Scripted: browser detection rule 2 of type dom-selector is missing selector`,
+		`002-programming-languages.json: Scripted: browser version rule 0 of type dom-attribute is missing attribute`,
+		`002-programming-languages.json: Scripted: browser version rule 0 has an invalid pattern: `,
+		`002-programming-languages.json: Uncategorized: no categories`,
+		`003-extra.json: unknown top-level field "version"`,
+		`004-broken.json: invalid JSON: `,
+		`005-invalid.json: missing or invalid apps object`,
+		`misc.json: file name does not follow the NNN-category.json layout`,
+	}
+	issues := make([]string, 0, len(l.issues))
+	for _, issue := range l.issues {
+		issues = append(issues, issue.String())
+	}
+	require.Len(t, issues, len(expected), "could not get all the issues: %v", issues)
+	for i, issue := range issues {
+		if strings.HasSuffix(expected[i], ": ") {
+			require.True(t, strings.HasPrefix(issue, expected[i]), "could not get issue %q, got %q", expected[i], issue)
+			continue
+		}
+		require.Equal(t, expected[i], issue, "could not get correct issue")
+	}
+
+	t.Run("without icons", func(t *testing.T) {
+		l := newLinter(dir, categories, "")
+		require.Nil(t, l.run(), "could not lint directory")
+		for _, issue := range l.issues {
+			require.NotContains(t, issue.Message, "icon", "could check icons without icons directory")
+		}
+	})
+}
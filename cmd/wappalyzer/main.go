@@ -8,7 +8,6 @@ import (
 	"os"
 
 	wappalyzer "github.com/projectdiscovery/wappalyzergo"
-	browserutil "github.com/projectdiscovery/wappalyzergo/internal/browser"
 )
 
 // Result holds the scan result for a single URL (simple format)
@@ -29,13 +28,25 @@ type DetailedResult struct {
 
 // TechnologyDetails contains detailed information about a detected technology
 type TechnologyDetails struct {
-	Version     string   `json:"version,omitempty"`
-	Categories  []string `json:"categories,omitempty"`
-	Description string   `json:"description,omitempty"`
-	Website     string   `json:"website,omitempty"`
+	Version     string                `json:"version,omitempty"`
+	Confidence  int                   `json:"confidence,omitempty"`
+	Categories  []string              `json:"categories,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Website     string                `json:"website,omitempty"`
+	Evidence    []wappalyzer.Evidence `json:"evidence,omitempty"`
 }
 
 func main() {
+	// Handle subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diagnostics":
+			os.Exit(runDiagnostics(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		}
+	}
+
 	// Set up usage function
 	flag.Usage = printUsage
 
@@ -64,10 +75,10 @@ func main() {
 		if !*silent {
 			fmt.Fprintf(os.Stderr, "[INFO] Loading fingerprints from: %s\n", *fingerprintsDir)
 		}
-		wappalyzerClient, err = loadFingerprintsFromDirectory(*fingerprintsDir)
+		wappalyzerClient, err = wappalyzer.NewFromDirectory(*fingerprintsDir, clientOptions()...)
 	} else {
 		// Use embedded fingerprints
-		wappalyzerClient, err = wappalyzer.New()
+		wappalyzerClient, err = wappalyzer.New(clientOptions()...)
 	}
 
 	if err != nil {
@@ -99,6 +110,12 @@ func main() {
 	}
 	defer writer.Close()
 
+	// Start one browser shared by all the scans
+	if !*staticMode {
+		browserPool = newBrowserPool()
+		defer browserPool.Close()
+	}
+
 	// Scan URLs
 	if *detailed {
 		processURLsDetailed(urls, wappalyzerClient)
@@ -106,11 +123,32 @@ func main() {
 		// Use concurrent scanner
 		if err := scanURLsConcurrent(urls, wappalyzerClient, writer, *concurrency); err != nil {
 			fmt.Fprintf(os.Stderr, "Error during scanning: %v\n", err)
+			if browserPool != nil {
+				browserPool.Close()
+			}
 			os.Exit(1)
 		}
 	}
 }
 
+// clientOptions returns the library options derived from the CLI flags
+func clientOptions() []wappalyzer.Option {
+	var options []wappalyzer.Option
+	if *evidence {
+		options = append(options, wappalyzer.WithEvidence())
+	}
+	if *minConfidence > 0 {
+		options = append(options, wappalyzer.WithMinConfidence(*minConfidence))
+	}
+	if *maxScriptBytes > 0 {
+		options = append(options, wappalyzer.WithMaxScriptBytes(*maxScriptBytes))
+	}
+	if *cacheFile != "" {
+		options = append(options, wappalyzer.WithCache(*cacheFile))
+	}
+	return options
+}
+
 // processURLsDetailed processes URLs and outputs detailed results
 func processURLsDetailed(urls []string, wappalyzerClient *wappalyzer.Wappalyze) {
 	var results []DetailedResult
@@ -135,8 +173,8 @@ func processURLsDetailed(urls []string, wappalyzerClient *wappalyzer.Wappalyze)
 			continue
 		}
 
-		fingerprints := wappalyzerClient.FingerprintWithInfo(headers, body)
-		result.Technologies = formatDetailedFingerprints(fingerprints)
+		detections := wappalyzerClient.FingerprintDetailed(headers, body)
+		result.Technologies = formatDetailedFingerprints(detections)
 
 		// Enhance with browser detection if not in static mode
 		if !*staticMode {
@@ -146,8 +184,8 @@ func processURLsDetailed(urls []string, wappalyzerClient *wappalyzer.Wappalyze)
 				simpleTech[name] = details.Version
 			}
 
-			// Setup browser detector
-			detector := browserutil.NewDetector(*headless, *userAgent, *waitTime)
+			// Setup browser detector, sharing the browser between URLs
+			detector := newBrowserDetector()
 			ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 			err := detector.EnhanceWithVersions(ctx, url, simpleTech, wappalyzerClient.GetFingerprints())
 			cancel()
@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	wappalyzer "github.com/projectdiscovery/wappalyzergo"
 )
 
 // OutputFormat represents the output format type
@@ -19,10 +21,12 @@ const (
 
 // ScanResult represents a single scan result
 type ScanResult struct {
-	URL          string            `json:"url"`
-	Technologies map[string]string `json:"technologies"`
-	Mode         string            `json:"mode,omitempty"`
-	Error        string            `json:"error,omitempty"`
+	URL          string                           `json:"url"`
+	Technologies map[string]string                `json:"technologies"`
+	Confidence   map[string]int                   `json:"confidence,omitempty"`
+	Evidence     map[string][]wappalyzer.Evidence `json:"evidence,omitempty"`
+	Mode         string                           `json:"mode,omitempty"`
+	Error        string                           `json:"error,omitempty"`
 }
 
 // OutputWriter interface for different output formats
@@ -76,17 +80,36 @@ func (w *TextWriter) WriteResult(result *ScanResult) error {
 	}
 
 	fmt.Fprintf(w.writer, "\n%s [%s]\n", result.URL, mode)
+
+	// Low confidence technologies are printed separately after the others
+	var lowConfidence []string
 	for tech, version := range result.Technologies {
+		if _, ok := result.Confidence[tech]; ok {
+			lowConfidence = append(lowConfidence, tech)
+			continue
+		}
 		if version != "" {
 			fmt.Fprintf(w.writer, "  [OK] %s:%s\n", tech, version)
 		} else {
 			fmt.Fprintf(w.writer, "  [OK] %s\n", tech)
 		}
+		w.writeEvidence(result.Evidence[tech])
+	}
+	for _, tech := range lowConfidence {
+		fmt.Fprintf(w.writer, "  [??] %s (confidence: %d%%)\n", wappalyzer.FormatAppVersion(tech, result.Technologies[tech]), result.Confidence[tech])
+		w.writeEvidence(result.Evidence[tech])
 	}
 
 	return nil
 }
 
+// writeEvidence writes the evidence lines for a technology
+func (w *TextWriter) writeEvidence(evidence []wappalyzer.Evidence) {
+	for _, item := range evidence {
+		fmt.Fprintf(w.writer, "       %s\n", formatEvidence(item))
+	}
+}
+
 func (w *TextWriter) Close() error {
 	if w.closeFunc != nil {
 		return w.closeFunc()
@@ -7,7 +7,6 @@ import (
 	"sync"
 
 	wappalyzer "github.com/projectdiscovery/wappalyzergo"
-	browserutil "github.com/projectdiscovery/wappalyzergo/internal/browser"
 )
 
 func scanURL(url string, wappalyzerClient *wappalyzer.Wappalyze) *ScanResult {
@@ -23,14 +22,24 @@ func scanURL(url string, wappalyzerClient *wappalyzer.Wappalyze) *ScanResult {
 		return result
 	}
 
-	// Get technologies from static analysis
-	fingerprints := wappalyzerClient.Fingerprint(headers, body)
-	result.Technologies = formatSimpleFingerprints(fingerprints)
+	// Get technologies from static analysis, cutting off pathological pages
+	staticCtx, staticCancel := context.WithTimeout(context.Background(), *timeout)
+	detections, err := wappalyzerClient.FingerprintDetailedContext(staticCtx, headers, body)
+	staticCancel()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Technologies = formatSimpleFingerprints(detections)
+	result.Confidence = formatConfidenceMap(detections)
+	if *evidence {
+		result.Evidence = formatEvidenceMap(detections)
+	}
 
 	// Enhance with browser-based detection if not in static mode
 	if !*staticMode {
-		// Setup browser detector
-		detector := browserutil.NewDetector(*headless, *userAgent, *waitTime)
+		// Setup browser detector, sharing the browser between scans
+		detector := newBrowserDetector()
 
 		// Create context with timeout
 		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
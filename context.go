@@ -0,0 +1,50 @@
+package wappalyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// CancelledError is returned by the context-aware fingerprinting methods
+// when the context is done before fingerprinting completes. It wraps the
+// context error, so errors.Is(err, context.DeadlineExceeded) can be used
+// to detect timeouts.
+type CancelledError struct {
+	// Part is the part being matched when the context was done
+	Part string
+	// Err is the error of the context
+	Err error
+}
+
+// Error returns the error message
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("fingerprinting cancelled during %s: %v", e.Part, e.Err)
+}
+
+// Unwrap returns the context error
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// checkContext returns a CancelledError for the part if the context is done
+func checkContext(ctx context.Context, part part) error {
+	if err := ctx.Err(); err != nil {
+		return &CancelledError{Part: part.String(), Err: err}
+	}
+	return nil
+}
+
+// contextReader is a reader failing once the context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(data []byte) (int, error) {
+	if err := checkContext(r.ctx, htmlPart); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(data)
+}
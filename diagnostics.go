@@ -0,0 +1,109 @@
+package wappalyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Diagnostic describes a fingerprint rule that could not be compiled.
+// The rule is ignored when matching.
+type Diagnostic struct {
+	// App is the name of the app of the rule
+	App string `json:"app"`
+	// Part is the part of the response the rule is for
+	// (header, cookie, meta, html, scriptSrc, scripts, js, dom, css)
+	Part string `json:"part"`
+	// Key is the header, cookie, meta or js key of the rule, or the
+	// dom selector and attribute for the dom rules
+	Key string `json:"key,omitempty"`
+	// Pattern is the raw pattern, or the dom selector, as present in the fingerprint
+	Pattern string `json:"pattern"`
+	// Message describes why the rule could not be compiled
+	Message string `json:"message"`
+}
+
+// String returns a description of the diagnostic
+func (d Diagnostic) String() string {
+	if d.Key != "" {
+		return fmt.Sprintf("invalid %s pattern %q for %s (%s): %s", d.Part, d.Pattern, d.App, d.Key, d.Message)
+	}
+	return fmt.Sprintf("invalid %s pattern %q for %s: %s", d.Part, d.Pattern, d.App, d.Message)
+}
+
+// DiagnosticsError is returned when fingerprints with invalid
+// rules are loaded in strict mode or with pattern validation.
+type DiagnosticsError struct {
+	Diagnostics []Diagnostic
+}
+
+// Error returns the description of all the diagnostics
+func (e *DiagnosticsError) Error() string {
+	descriptions := make([]string, 0, len(e.Diagnostics))
+	for _, diagnostic := range e.Diagnostics {
+		descriptions = append(descriptions, diagnostic.String())
+	}
+	return fmt.Sprintf("found %d invalid fingerprint rules:\n%s", len(e.Diagnostics), strings.Join(descriptions, "\n"))
+}
+
+// newDiagnostic creates a new diagnostic for a rule of an app
+func newDiagnostic(app string, part part, key, pattern string, err error) Diagnostic {
+	return Diagnostic{
+		App:     app,
+		Part:    part.String(),
+		Key:     key,
+		Pattern: pattern,
+		Message: err.Error(),
+	}
+}
+
+// compilePatterns compiles the regexes of all the patterns upfront,
+// returning a diagnostic for every invalid pattern.
+func (f *CompiledFingerprints) compilePatterns() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, app := range sortedKeys(f.Apps) {
+		f.Apps[app].forEachPattern(func(part part, key string, pattern *ParsedPattern) {
+			if err := pattern.compile(); err != nil {
+				diagnostics = append(diagnostics, newDiagnostic(app, part, key, pattern.raw, err))
+			}
+		})
+	}
+	return diagnostics
+}
+
+// validatePatterns returns a diagnostic for every pattern with an invalid
+// regex, without compiling the regexes.
+func (f *CompiledFingerprints) validatePatterns() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, app := range sortedKeys(f.Apps) {
+		f.Apps[app].forEachPattern(func(part part, key string, pattern *ParsedPattern) {
+			if err := pattern.validate(); err != nil {
+				diagnostics = append(diagnostics, newDiagnostic(app, part, key, pattern.raw, err))
+			}
+		})
+	}
+	return diagnostics
+}
+
+// compileDiagnostics returns the diagnostics of the rules rejected when
+// compiling the fingerprints.
+func (f *CompiledFingerprints) compileDiagnostics() []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, app := range sortedKeys(f.Apps) {
+		diagnostics = append(diagnostics, f.Apps[app].diagnostics...)
+	}
+	return diagnostics
+}
+
+// sortDiagnostics sorts the diagnostics by app, part and key
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].App != diagnostics[j].App {
+			return diagnostics[i].App < diagnostics[j].App
+		}
+		if diagnostics[i].Part != diagnostics[j].Part {
+			return diagnostics[i].Part < diagnostics[j].Part
+		}
+		return diagnostics[i].Key < diagnostics[j].Key
+	})
+}
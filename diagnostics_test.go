@@ -0,0 +1,63 @@
+package wappalyzer
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics(t *testing.T) {
+	fsys := fstest.MapFS{
+		"custom.json": {Data: []byte(`{"apps": {
+			"Custom App": {"headers": {"server": "custom", "x-custom": "(?!other)custom"}, "html": ["<div(?<=x)"]},
+			"Custom Dom": {"dom": {"div >> p": {"exists": ""}, "#app": {"text": 1, "attributes": {"data-app": "app"}}}}
+		}}`)},
+	}
+
+	wappalyzer, err := NewFromFS(fsys)
+	require.Nil(t, err, "could not create wappalyzer")
+
+	diagnostics := wappalyzer.LoadReport().Diagnostics
+	require.Len(t, diagnostics, 4, "could not get diagnostics")
+	require.Equal(t, diagnostics, wappalyzer.Diagnostics(), "could not get load report diagnostics")
+	require.Nil(t, wappalyzer.GetCompiledFingerprints().Apps["Custom App"].headers["server"].regex, "could not keep pattern compilation lazy")
+	require.Equal(t, Diagnostic{
+		App:     "Custom App",
+		Part:    "header",
+		Key:     "x-custom",
+		Pattern: "(?!other)custom",
+		Message: "error parsing regexp: invalid or unsupported Perl syntax: `(?!`",
+	}, diagnostics[0], "could not get pattern diagnostic")
+	require.Equal(t, []string{"Custom App", "Custom App", "Custom Dom", "Custom Dom"}, []string{diagnostics[0].App, diagnostics[1].App, diagnostics[2].App, diagnostics[3].App}, "could not sort diagnostics")
+	require.Equal(t, "html", diagnostics[1].Part, "could not sort diagnostics")
+	require.Equal(t, "#app", diagnostics[3].Key, "could not get dom value diagnostic")
+	require.Equal(t, "div >> p", diagnostics[2].Pattern, "could not get dom selector diagnostic")
+
+	fingerprints := wappalyzer.Fingerprint(map[string][]string{"Server": {"custom"}}, []byte(`<div id="app" data-app="app"></div>`))
+	require.Equal(t, map[string]struct{}{"Custom App": {}, "Custom Dom": {}}, fingerprints, "could not match valid rules")
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := NewFromFS(fsys, WithStrictMode())
+		var diagnosticsErr *DiagnosticsError
+		require.True(t, errors.As(err, &diagnosticsErr), "could not get diagnostics error")
+		require.Equal(t, diagnostics, diagnosticsErr.Diagnostics, "could not get strict diagnostics")
+
+		_, err = NewFromFS(fstest.MapFS{"custom.json": {Data: []byte(`{"apps": {"Custom App": {"headers": {"server": "custom"}}}}`)}}, WithStrictMode())
+		require.Nil(t, err, "could not load valid fingerprints in strict mode")
+	})
+
+	t.Run("pattern-validation", func(t *testing.T) {
+		_, err := NewFromFS(fsys, WithPatternValidation())
+		var diagnosticsErr *DiagnosticsError
+		require.True(t, errors.As(err, &diagnosticsErr), "could not get diagnostics error")
+		require.Len(t, diagnosticsErr.Diagnostics, 2, "could not get invalid patterns")
+
+		valid := fstest.MapFS{"custom.json": {Data: []byte(`{"apps": {"Custom Dom": {"dom": {"div >> p": {"exists": ""}}}}}`)}}
+		_, err = NewFromFS(valid, WithPatternValidation())
+		require.Nil(t, err, "could fail pattern validation on invalid selector")
+		_, err = NewFromFS(valid, WithStrictMode())
+		require.NotNil(t, err, "could not fail strict mode on invalid selector")
+	})
+}
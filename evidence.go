@@ -0,0 +1,46 @@
+package wappalyzer
+
+import "strings"
+
+// Evidence describes a single pattern that fired for a detection
+type Evidence struct {
+	// Part is the part of the response that was matched
+	// (header, cookie, meta, html, scriptSrc, scripts, js, dom, css)
+	Part string `json:"part"`
+	// Key is the header, cookie, meta or js key the pattern was evaluated for
+	Key string `json:"key,omitempty"`
+	// Pattern is the raw pattern string from the fingerprint
+	Pattern string `json:"pattern"`
+	// Match is the substring of the input matched by the pattern
+	Match string `json:"match"`
+	// Confidence is the confidence of the pattern
+	Confidence int `json:"confidence"`
+}
+
+// maxEvidenceMatchLength is the maximum length of a recorded match
+const maxEvidenceMatchLength = 256
+
+// newEvidence creates a new evidence for a pattern match
+func newEvidence(part part, key string, pattern *ParsedPattern, match string) Evidence {
+	return Evidence{
+		Part:       part.String(),
+		Key:        key,
+		Pattern:    pattern.raw,
+		Match:      match,
+		Confidence: pattern.Confidence,
+	}
+}
+
+// cloneEvidence copies evidences so that they do not reference
+// the memory of the matched input.
+func cloneEvidence(evidence []Evidence) []Evidence {
+	cloned := make([]Evidence, len(evidence))
+	for i, item := range evidence {
+		if len(item.Match) > maxEvidenceMatchLength {
+			item.Match = item.Match[:maxEvidenceMatchLength]
+		}
+		item.Match = strings.Clone(item.Match)
+		cloned[i] = item
+	}
+	return cloned
+}
@@ -2,87 +2,155 @@ package wappalyzer
 
 import (
 	"bytes"
+	"context"
+	"strings"
 	"unsafe"
 
 	"golang.org/x/net/html"
 )
 
-// checkBody checks for fingerprints in the HTML body
+// checkBody checks the html fingerprints against the lowercased HTML body
 func (s *Wappalyze) checkBody(body []byte) []matchPartResult {
-	var technologies []matchPartResult
+	return s.fingerprints.matchString(unsafeToString(body), htmlPart, s.evidence)
+}
 
-	bodyString := unsafeToString(body)
+// checkDocument checks the elements of the parsed HTML document for
+// fingerprints: script sources, inline scripts, meta tags and the dom,
+// js and css rules.
+func (s *Wappalyze) checkDocument(ctx context.Context, elements []*html.Node) ([]matchPartResult, error) {
+	checker := s.newDocumentChecker()
+	for _, element := range elements {
+		if err := checker.checkElement(ctx, element); err != nil {
+			return nil, err
+		}
+	}
+	return checker.finish(ctx, elements)
+}
 
-	technologies = append(
-		technologies,
-		s.fingerprints.matchString(bodyString, htmlPart)...,
-	)
+// documentChecker checks the elements of a document one at a time, so
+// that they can be checked while the document is streamed. The js
+// globals and the stylesheets of all the elements are matched at once
+// when the checks are finished.
+type documentChecker struct {
+	s *Wappalyze
+
+	technologies []matchPartResult
+	// scriptBytes is the number of inline script bytes scanned so far
+	scriptBytes int
+
+	// globals contains the js globals extracted from the scripts
+	globals map[string]string
+	// globalBytes is the number of inline script bytes scanned for globals
+	globalBytes int
+	// fetchedScripts is the number of external scripts fetched
+	fetchedScripts int
+
+	// styles contains the stylesheets of the document
+	styles strings.Builder
+	// fetchedStylesheets is the number of linked stylesheets fetched
+	fetchedStylesheets int
+}
 
-	// Tokenize the HTML document and check for fingerprints as required
-	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+// newDocumentChecker creates a new checker for the elements of a document
+func (s *Wappalyze) newDocumentChecker() *documentChecker {
+	return &documentChecker{
+		s:       s,
+		globals: make(map[string]string),
+	}
+}
 
-	for {
-		tt := tokenizer.Next()
-		switch tt {
-		case html.ErrorToken:
-			return technologies
-		case html.StartTagToken:
-			token := tokenizer.Token()
-			switch token.Data {
-			case "script":
-				// Check if the script tag has a source file to check
-				source, found := getScriptSource(token)
-				if found {
-					// Check the script tags for script fingerprints
-					technologies = append(
-						technologies,
-						s.fingerprints.matchString(source, scriptPart)...,
-					)
-					continue
-				}
+// checkElement checks an element of the document. The text content of
+// the element must be complete.
+func (c *documentChecker) checkElement(ctx context.Context, element *html.Node) error {
+	if err := c.checkScriptOrMeta(ctx, element); err != nil {
+		return err
+	}
+	if err := c.checkGlobals(ctx, element); err != nil {
+		return err
+	}
+	return c.checkStyles(ctx, element)
+}
 
-				// Check the text attribute of the tag for javascript based technologies.
-				// The next token should be the contents of the script tag
-				if tokenType := tokenizer.Next(); tokenType != html.TextToken {
-					continue
-				}
+// finish checks the dom rules against the elements of the document and
+// the js and css rules against what was collected from the checked
+// elements, returning all the matches of the document.
+func (c *documentChecker) finish(ctx context.Context, elements []*html.Node) ([]matchPartResult, error) {
+	technologies := c.technologies
 
-				// TODO: JS requires a running VM, for checking properties. Only
-				// possible with headless for now :(
-
-				// data := tokenizer.Token().Data
-				// technologies = append(
-				// 	technologies,
-				// 	s.fingerprints.matchString(data, jsPart)...,
-				// )
-			case "meta":
-				// For meta tag, we are only interested in name and content attributes.
-				name, content, found := getMetaNameAndContent(token)
-				if !found {
-					continue
-				}
-				technologies = append(
-					technologies,
-					s.fingerprints.matchKeyValueString(name, content, metaPart)...,
-				)
-			}
-		case html.SelfClosingTagToken:
-			token := tokenizer.Token()
-			if token.Data != "meta" {
-				continue
-			}
+	matches, err := c.s.checkDOM(ctx, elements)
+	if err != nil {
+		return nil, err
+	}
+	technologies = append(technologies, matches...)
+	technologies = append(technologies, c.matchGlobals()...)
+	technologies = append(technologies, c.matchStyles()...)
+	return technologies, nil
+}
 
-			// Parse the meta tag and check for tech
-			name, content, found := getMetaNameAndContent(token)
-			if !found {
-				continue
-			}
-			technologies = append(
-				technologies,
-				s.fingerprints.matchKeyValueString(name, content, metaPart)...,
+// checkScriptOrMeta checks the script and meta elements of the document
+func (c *documentChecker) checkScriptOrMeta(ctx context.Context, element *html.Node) error {
+	if element.Data != "script" && element.Data != "meta" {
+		return nil
+	}
+	if err := checkContext(ctx, scriptsPart); err != nil {
+		return err
+	}
+	s := c.s
+
+	switch element.Data {
+	case "script":
+		// Check if the script tag has a source file to check
+		source, found := getScriptSource(element)
+		if found {
+			// Check the script tags for script fingerprints
+			c.technologies = append(
+				c.technologies,
+				s.fingerprints.matchString(strings.ToLower(source), scriptPart, s.evidence)...,
 			)
+			return nil
+		}
+
+		// Check the inline script contents for script fingerprints
+		if data, ok := s.limitScript(elementRawText(element), &c.scriptBytes); ok {
+			c.technologies = append(
+				c.technologies,
+				s.fingerprints.matchString(strings.ToLower(data), scriptsPart, s.evidence)...,
+			)
+		}
+
+		// JS properties are checked by checkGlobals, using globals
+		// statically extracted from the scripts.
+	case "meta":
+		// For meta tag, we are only interested in name and content attributes.
+		name, content, found := getMetaNameAndContent(element)
+		if !found {
+			return nil
+		}
+		c.technologies = append(
+			c.technologies,
+			s.fingerprints.matchKeyValueString(strings.ToLower(name), strings.ToLower(content), metaPart, s.evidence)...,
+		)
+	}
+	return nil
+}
+
+// limitScript returns the part of the inline script that can still be
+// scanned within the configured limit and updates the scanned count.
+func (s *Wappalyze) limitScript(data string, scanned *int) (string, bool) {
+	if len(data) == 0 {
+		return "", false
+	}
+	if s.maxScriptBytes > 0 {
+		remaining := s.maxScriptBytes - *scanned
+		if remaining <= 0 {
+			return "", false
+		}
+		if len(data) > remaining {
+			data = data[:remaining]
 		}
 	}
+	*scanned += len(data)
+	return data, true
 }
 
 func (s *Wappalyze) getTitle(body []byte) string {
@@ -110,14 +178,14 @@ func (s *Wappalyze) getTitle(body []byte) string {
 	}
 }
 
-// getMetaNameAndContent gets name and content attributes from meta html token
-func getMetaNameAndContent(token html.Token) (string, string, bool) {
-	if len(token.Attr) < keyValuePairLength {
+// getMetaNameAndContent gets name and content attributes from a meta element
+func getMetaNameAndContent(element *html.Node) (string, string, bool) {
+	if len(element.Attr) < keyValuePairLength {
 		return "", "", false
 	}
 
 	var name, content string
-	for _, attr := range token.Attr {
+	for _, attr := range element.Attr {
 		switch attr.Key {
 		case "name":
 			name = attr.Val
@@ -128,20 +196,20 @@ func getMetaNameAndContent(token html.Token) (string, string, bool) {
 	return name, content, true
 }
 
-// getScriptSource gets src tag from a script tag
-func getScriptSource(token html.Token) (string, bool) {
-	if len(token.Attr) < 1 {
+// getScriptSource gets src attribute from a script element
+func getScriptSource(element *html.Node) (string, bool) {
+	if len(element.Attr) < 1 {
 		return "", false
 	}
 
 	var source string
-	for _, attr := range token.Attr {
+	for _, attr := range element.Attr {
 		switch attr.Key {
 		case "src":
 			source = attr.Val
 		}
 	}
-	return source, true
+	return source, source != ""
 }
 
 // unsafeToString converts a byte slice to string and does it with
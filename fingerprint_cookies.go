@@ -10,7 +10,7 @@ func (s *Wappalyze) checkCookies(cookies []string) []matchPartResult {
 	// Normalize the cookies for further processing
 	normalized := s.normalizeCookies(cookies)
 
-	technologies := s.fingerprints.matchMapString(normalized, cookiesPart)
+	technologies := s.fingerprints.matchMapString(normalized, cookiesPart, s.evidence)
 	return technologies
 }
 
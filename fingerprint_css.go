@@ -0,0 +1,89 @@
+package wappalyzer
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// maxFetchedStylesheets is the maximum number of linked stylesheets fetched per page
+	maxFetchedStylesheets = 10
+	// maxCSSBytes is the maximum number of stylesheet bytes matched per page
+	maxCSSBytes = 2 * 1024 * 1024
+)
+
+// checkStyles collects the stylesheets of an element of the document,
+// which are checked against the css fingerprints by matchStyles: <style>
+// blocks, style attributes and, when a resource fetcher is configured,
+// stylesheets linked with <link rel=stylesheet>.
+func (c *documentChecker) checkStyles(ctx context.Context, element *html.Node) error {
+	if c.styles.Len() >= maxCSSBytes {
+		return nil
+	}
+	if err := checkContext(ctx, cssPart); err != nil {
+		return err
+	}
+	s := c.s
+
+	if style, ok := elementAttribute(element, "style"); ok && style != "" {
+		c.writeStyle(style)
+	}
+
+	switch element.Data {
+	case "style":
+		c.writeStyle(elementRawText(element))
+	case "link":
+		if s.resourceFetcher == nil || c.fetchedStylesheets >= maxFetchedStylesheets || !isStylesheetLink(element) {
+			return nil
+		}
+		href, ok := elementAttribute(element, "href")
+		if !ok || href == "" {
+			return nil
+		}
+		c.fetchedStylesheets++
+
+		data, err := s.resourceFetcher(ctx, href)
+		if err != nil {
+			return checkContext(ctx, cssPart)
+		}
+		c.writeStyle(string(data))
+	}
+	return nil
+}
+
+// writeStyle appends a stylesheet to the collected ones, up to maxCSSBytes
+func (c *documentChecker) writeStyle(data string) {
+	remaining := maxCSSBytes - c.styles.Len()
+	if remaining <= 0 {
+		return
+	}
+	if len(data) > remaining {
+		data = data[:remaining]
+	}
+	c.styles.WriteString(data)
+	c.styles.WriteByte('\n')
+}
+
+// matchStyles checks the css fingerprints against the collected stylesheets
+func (c *documentChecker) matchStyles() []matchPartResult {
+	if c.styles.Len() == 0 {
+		return nil
+	}
+	return c.s.fingerprints.matchString(c.styles.String(), cssPart, c.s.evidence)
+}
+
+// isStylesheetLink returns true if a link element references a stylesheet
+func isStylesheetLink(element *html.Node) bool {
+	rel, ok := elementAttribute(element, "rel")
+	if !ok {
+		return false
+	}
+	for _, value := range strings.Fields(rel) {
+		if strings.EqualFold(value, "stylesheet") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,490 @@
+package wappalyzer
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// domTextKey is the key under which the exists and text patterns
+// of a dom fingerprint are stored in the compiled dom rules.
+const domTextKey = "main"
+
+// maxDOMTextLength is the maximum length of element text evaluated
+// against the dom text patterns.
+const maxDOMTextLength = 4096
+
+// parseDocument parses the HTML body into a tree and returns its elements.
+// The context is checked while the body is parsed.
+func parseDocument(ctx context.Context, body []byte) ([]*html.Node, error) {
+	document, err := html.Parse(&contextReader{ctx: ctx, reader: bytes.NewReader(body)})
+	if err != nil {
+		if cancelled := checkContext(ctx, htmlPart); cancelled != nil {
+			return nil, cancelled
+		}
+		return nil, nil
+	}
+	return collectElements(document), nil
+}
+
+// checkDOM checks the dom fingerprints against the elements of
+// the document using their css selectors.
+func (s *Wappalyze) checkDOM(ctx context.Context, elements []*html.Node) ([]matchPartResult, error) {
+	return s.fingerprints.matchDOM(ctx, elements, s.evidence)
+}
+
+// collectElements returns all the element nodes of the document in order
+func collectElements(document *html.Node) []*html.Node {
+	var elements []*html.Node
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			elements = append(elements, node)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(document)
+	return elements
+}
+
+// domIndex maps the keys of the elements, see elementKeys, to the dom
+// selectors that can match them, so that only these selectors are tried
+// on every element of a document.
+type domIndex struct {
+	// apps contains the apps with dom selectors, in order
+	apps []domApp
+	// selectors contains the positions of the selectors by element key
+	selectors map[string][]int
+	// always contains the positions of the selectors tried on every element
+	always []int
+	// count is the number of selectors
+	count int
+}
+
+// domApp contains the dom selectors of an app, in order, which are
+// numbered from start in the index.
+type domApp struct {
+	name  string
+	doms  []string
+	start int
+}
+
+// getDOMIndex returns the dom index of the fingerprints, building it if needed
+func (f *CompiledFingerprints) getDOMIndex() *domIndex {
+	f.indexes.domOnce.Do(func() {
+		f.indexes.dom = newDOMIndex(f.Apps, true)
+	})
+	return f.indexes.dom
+}
+
+// newDOMIndex builds the dom index of the apps. Without keys, every
+// selector is tried on every element.
+func newDOMIndex(apps map[string]*CompiledFingerprint, keys bool) *domIndex {
+	index := &domIndex{selectors: make(map[string][]int)}
+	for _, app := range sortedKeys(apps) {
+		fingerprint := apps[app]
+		if len(fingerprint.domSelectors) == 0 {
+			continue
+		}
+		doms := sortedKeys(fingerprint.domSelectors)
+		index.apps = append(index.apps, domApp{name: app, doms: doms, start: index.count})
+
+		for _, dom := range doms {
+			position := index.count
+			index.count++
+
+			domKeys, ok := fingerprint.domKeys[dom]
+			if !keys || !ok {
+				index.always = append(index.always, position)
+				continue
+			}
+			for _, key := range domKeys {
+				index.selectors[key] = append(index.selectors[key], position)
+			}
+		}
+	}
+	return index
+}
+
+// candidates returns the elements of the document each selector can
+// match, in document order, by the position of the selector.
+func (index *domIndex) candidates(elements []*html.Node) [][]*html.Node {
+	candidates := make([][]*html.Node, index.count)
+	for _, position := range index.always {
+		candidates[position] = elements
+	}
+
+	var keys []string
+	for _, element := range elements {
+		keys = elementKeys(element, keys[:0])
+		for _, key := range keys {
+			for _, position := range index.selectors[key] {
+				// A selector can be reached from several keys of an element
+				matched := candidates[position]
+				if len(matched) > 0 && matched[len(matched)-1] == element {
+					continue
+				}
+				candidates[position] = append(matched, element)
+			}
+		}
+	}
+	return candidates
+}
+
+// elementKeys appends the keys of an element to keys: its tag name,
+// "#" followed by its id, "." followed by each of its classes and
+// "[" followed by the name of each of its attributes, with and without
+// the tag name in front.
+func elementKeys(element *html.Node, keys []string) []string {
+	keys = append(keys, element.Data)
+	for _, attr := range element.Attr {
+		switch attr.Key {
+		case "id":
+			keys = append(keys, "#"+attr.Val)
+		case "class":
+			for _, class := range strings.Fields(attr.Val) {
+				keys = append(keys, "."+class)
+			}
+		}
+		keys = append(keys, "["+attr.Key, element.Data+"["+attr.Key)
+	}
+	return keys
+}
+
+// selectorKeys returns the element keys of a selector group, one of
+// which every element matched by the group has. For every selector of
+// the group, the key is taken from the compound selector of its subject:
+// its id, first class, tag name and first attribute, first attribute or
+// tag name, in that order.
+//
+// It is conservative, and returns false for selectors it does not
+// fully understand, which are then tried on every element.
+func selectorKeys(group string) ([]string, bool) {
+	var keys []string
+	for _, selector := range splitSelector(group, ',', false) {
+		compounds := splitSelector(selector, 0, true)
+		if len(compounds) == 0 {
+			return nil, false
+		}
+		key, ok := compoundKey(compounds[len(compounds)-1])
+		if !ok {
+			return nil, false
+		}
+		keys = append(keys, key)
+	}
+	return keys, len(keys) > 0
+}
+
+// splitSelector splits a selector on a separator outside of brackets,
+// parentheses and strings. With combinators, it splits on the
+// combinators and whitespace instead.
+func splitSelector(selector string, separator byte, combinators bool) []string {
+	var parts []string
+	var depth int
+	var quote byte
+	start := 0
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '"' || c == '\'':
+			quote = c
+			continue
+		case c == '[' || c == '(':
+			depth++
+			continue
+		case c == ']' || c == ')':
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+		if (combinators && (c == ' ' || c == '\t' || c == '\n' || c == '>' || c == '+' || c == '~')) || (!combinators && c == separator) {
+			if part := strings.TrimSpace(selector[start:i]); part != "" {
+				parts = append(parts, part)
+			}
+			start = i + 1
+		}
+	}
+	if part := strings.TrimSpace(selector[start:]); part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// compoundKey returns the element key of a compound selector such as
+// div#main.container[data-id]:not(.hidden)
+func compoundKey(compound string) (string, bool) {
+	var tag, id, class, attr string
+	i := 0
+	if compound[0] == '*' {
+		i++
+	} else {
+		tag = strings.ToLower(selectorName(compound))
+		i += len(tag)
+	}
+	for i < len(compound) {
+		switch compound[i] {
+		case '#', '.':
+			name := selectorName(compound[i+1:])
+			if name == "" {
+				return "", false
+			}
+			if compound[i] == '#' && id == "" {
+				id = name
+			} else if compound[i] == '.' && class == "" {
+				class = name
+			}
+			i += 1 + len(name)
+		case '[':
+			end := closingBracket(compound, i)
+			name := selectorName(compound[i+1:])
+			if end == -1 || name == "" {
+				return "", false
+			}
+			// Attributes that are not equal to a value also match the
+			// elements without the attribute, and escaped or namespaced
+			// names are not supported.
+			rest := compound[i+1+len(name):]
+			if !strings.HasPrefix(rest, "]") && !strings.HasPrefix(rest, "=") && !strings.ContainsAny(rest[:1], "~^$*|") {
+				return "", false
+			}
+			if strings.HasPrefix(rest, "|") && !strings.HasPrefix(rest, "|=") {
+				return "", false
+			}
+			if attr == "" {
+				attr = strings.ToLower(name)
+			}
+			i = end + 1
+		case ':':
+			// Pseudo-classes only filter the elements further
+			i++
+			for i < len(compound) && compound[i] == ':' {
+				i++
+			}
+			i += len(selectorName(compound[i:]))
+			if i < len(compound) && compound[i] == '(' {
+				end := closingBracket(compound, i)
+				if end == -1 {
+					return "", false
+				}
+				i = end + 1
+			}
+		default:
+			return "", false
+		}
+	}
+
+	switch {
+	case id != "":
+		return "#" + id, true
+	case class != "":
+		return "." + class, true
+	case attr != "":
+		return tag + "[" + attr, true
+	case tag != "":
+		return tag, true
+	}
+	return "", false
+}
+
+// selectorName returns the identifier at the start of a selector
+func selectorName(selector string) string {
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		if c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80 {
+			continue
+		}
+		return selector[:i]
+	}
+	return selector
+}
+
+// closingBracket returns the index of the bracket or parenthesis closing
+// the one at start, skipping nested ones and strings, or -1.
+func closingBracket(selector string, start int) int {
+	var depth int
+	var quote byte
+	for i := start; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchDOM matches the dom fingerprints against the elements of a document.
+//
+// The exists and text patterns are evaluated against the text content of
+// every element matched by the selector, and the attribute patterns against
+// the value of the respective attribute. Only the elements that can match
+// a selector according to the dom index are tried. The evidence of the
+// matches is only built with recordEvidence.
+func (f *CompiledFingerprints) matchDOM(ctx context.Context, elements []*html.Node, recordEvidence bool) ([]matchPartResult, error) {
+	return f.matchDOMIndex(ctx, elements, f.getDOMIndex(), recordEvidence)
+}
+
+// matchDOMIndex matches the dom fingerprints using a dom index
+func (f *CompiledFingerprints) matchDOMIndex(ctx context.Context, elements []*html.Node, index *domIndex, recordEvidence bool) ([]matchPartResult, error) {
+	var technologies []matchPartResult
+	if len(elements) == 0 {
+		return technologies, nil
+	}
+	candidates := index.candidates(elements)
+
+	for _, domApp := range index.apps {
+		if err := checkContext(ctx, domPart); err != nil {
+			return nil, err
+		}
+		app := domApp.name
+		fingerprint := f.Apps[app]
+
+		var matched bool
+		var version string
+		var evidence []Evidence
+		confidence := 100
+
+		for i, dom := range domApp.doms {
+			selector := fingerprint.domSelectors[dom]
+			patterns := fingerprint.dom[dom]
+			attrs := sortedKeys(patterns)
+
+			for _, element := range candidates[domApp.start+i] {
+				if !selector.Match(element) {
+					continue
+				}
+
+				var elementMatched bool
+				for _, attr := range attrs {
+					pattern := patterns[attr]
+
+					var value string
+					key := dom
+					if attr == domTextKey {
+						value = elementText(element)
+					} else {
+						attrValue, ok := elementAttribute(element, attr)
+						if !ok {
+							continue
+						}
+						value = attrValue
+						key = dom + " @" + attr
+					}
+
+					if valid, versionString, match := pattern.evaluate(value); valid {
+						elementMatched = true
+						if version == "" && versionString != "" {
+							version = versionString
+						}
+						confidence = pattern.Confidence
+						if recordEvidence {
+							evidence = append(evidence, newEvidence(domPart, key, pattern, match))
+						}
+					}
+				}
+				// Only the first matching element is needed for a selector
+				if elementMatched {
+					matched = true
+					break
+				}
+			}
+		}
+
+		if !matched {
+			continue
+		}
+		technologies = append(technologies, matchPartResult{
+			application: app,
+			version:     version,
+			confidence:  confidence,
+			evidence:    evidence,
+		})
+	}
+	return technologies, nil
+}
+
+// elementText returns the text content of an element, truncated
+// to maxDOMTextLength bytes.
+func elementText(element *html.Node) string {
+	builder := &strings.Builder{}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		if builder.Len() >= maxDOMTextLength {
+			return
+		}
+		if node.Type == html.TextNode {
+			builder.WriteString(node.Data)
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(element)
+
+	text := builder.String()
+	if len(text) > maxDOMTextLength {
+		text = text[:maxDOMTextLength]
+	}
+	return text
+}
+
+// elementRawText returns the full text of a raw text element
+// such as <script> or <style>.
+func elementRawText(element *html.Node) string {
+	if element.FirstChild != nil && element.FirstChild == element.LastChild {
+		return element.FirstChild.Data
+	}
+
+	builder := &strings.Builder{}
+	for child := element.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			builder.WriteString(child.Data)
+		}
+	}
+	return builder.String()
+}
+
+// elementAttribute returns the value of an attribute of an element
+func elementAttribute(element *html.Node, name string) (string, bool) {
+	for _, attr := range element.Attr {
+		if attr.Namespace == "" && strings.EqualFold(attr.Key, name) {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,492 @@
+package wappalyzer
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ResourceFetcher fetches a resource referenced by the page, such as a
+// script or a stylesheet. The URL is passed as present in the document,
+// so the fetcher is responsible for resolving relative URLs. The context
+// is the one of the fingerprinting call and should bound the request.
+type ResourceFetcher func(ctx context.Context, url string) ([]byte, error)
+
+const (
+	// staticJSConfidence is the percentage of the pattern confidence used
+	// for js rules matched by static analysis instead of a running browser.
+	staticJSConfidence = 50
+	// maxFetchedScripts is the maximum number of external scripts fetched per page
+	maxFetchedScripts = 10
+	// maxJSGlobals is the maximum number of globals extracted per page
+	maxJSGlobals = 10000
+	// maxJSValueLength is the maximum length of an extracted global value
+	maxJSValueLength = 1024
+	// maxJSDepth is the maximum depth of object literals walked
+	maxJSDepth = 8
+)
+
+// checkGlobals extracts global assignments from an inline or fetched
+// script of the document, which are checked against the js fingerprints
+// by matchGlobals.
+//
+// This is a best-effort alternative to evaluating the js rules in a
+// browser, so the resulting confidence is lowered.
+func (c *documentChecker) checkGlobals(ctx context.Context, element *html.Node) error {
+	if element.Data != "script" {
+		return nil
+	}
+	if err := checkContext(ctx, jsPart); err != nil {
+		return err
+	}
+	s := c.s
+
+	if source, ok := elementAttribute(element, "src"); ok && source != "" {
+		if s.resourceFetcher == nil || c.fetchedScripts >= maxFetchedScripts {
+			return nil
+		}
+		c.fetchedScripts++
+
+		data, err := s.resourceFetcher(ctx, source)
+		if err != nil {
+			return checkContext(ctx, jsPart)
+		}
+		extractJSGlobals(string(data), c.globals)
+		return nil
+	}
+
+	// Inline scripts share the byte budget of the scripts patterns
+	script, ok := s.limitScript(elementRawText(element), &c.globalBytes)
+	if !ok {
+		return nil
+	}
+	scriptType, _ := elementAttribute(element, "type")
+	if strings.Contains(strings.ToLower(scriptType), "json") {
+		// JSON config blobs with an id are commonly exposed as globals
+		if id, ok := elementAttribute(element, "id"); ok && id != "" {
+			extractJSONGlobal(id, script, c.globals)
+		}
+		return nil
+	}
+	extractJSGlobals(script, c.globals)
+	return nil
+}
+
+// matchGlobals checks the js fingerprints against the extracted globals
+func (c *documentChecker) matchGlobals() []matchPartResult {
+	if len(c.globals) == 0 {
+		return nil
+	}
+
+	technologies := c.s.fingerprints.matchMapString(c.globals, jsPart, c.s.evidence)
+	for i := range technologies {
+		technologies[i].confidence = technologies[i].confidence * staticJSConfidence / 100
+	}
+	return technologies
+}
+
+// jsAssignmentRegex matches the left-hand side of a global assignment
+// such as `var X =`, `window.X.Y =` or `X.version =`. The second group
+// is the global object the assignment is made on, if any.
+var jsAssignmentRegex = regexp.MustCompile(`(?:\b(?:var|let|const)\s+)?(?:\b(window|self|globalThis)\s*\.\s*)?([A-Za-z_$][\w$]*(?:\s*\.\s*[A-Za-z_$][\w$]*)*)\s*=[^=>]`)
+
+// extractJSGlobals extracts obvious global assignments from a script
+// into globals, as "path.to.property" -> value. Objects and values that
+// are not literals are recorded with an empty value.
+//
+// Only the top-level statements of the script are considered, as well as
+// the assignments to the properties of window, self or globalThis inside
+// functions and blocks. Assignments in strings and comments are skipped.
+func extractJSGlobals(script string, globals map[string]string) {
+	scanner := &jsScanner{src: script}
+	for _, indexes := range jsAssignmentRegex.FindAllStringSubmatchIndex(script, -1) {
+		if len(globals) >= maxJSGlobals {
+			return
+		}
+
+		depth, ok := scanner.advance(indexes[0])
+		if !ok {
+			continue
+		}
+		// Assignments in a block are local unless made on the global object
+		if depth > 0 && indexes[2] == -1 {
+			continue
+		}
+
+		// Skip members of expressions such as `a[0].b =` or `f().b =`
+		if start := indexes[0]; start > 0 {
+			switch script[start-1] {
+			case '.', ']', ')':
+				continue
+			}
+		}
+
+		path := strings.Join(strings.Fields(strings.ReplaceAll(script[indexes[4]:indexes[5]], ".", " ")), ".")
+		if path == "this" || strings.HasPrefix(path, "this.") {
+			continue
+		}
+
+		parser := &jsLiteralParser{src: script, pos: indexes[1] - 1, globals: globals}
+		parser.setParents(path)
+		parser.parseValue(path, 0)
+	}
+}
+
+// jsScanner tracks the brace depth of a script, skipping its strings
+// and comments. Regular expression literals are not recognized.
+type jsScanner struct {
+	src   string
+	pos   int
+	depth int
+}
+
+// advance scans the script up to offset, which must not decrease between
+// calls, returning the brace depth at offset and false if offset is in a
+// string or a comment.
+func (s *jsScanner) advance(offset int) (int, bool) {
+	if offset < s.pos {
+		// The offset was skipped along with a string or a comment
+		return s.depth, false
+	}
+	for s.pos < offset {
+		switch c := s.src[s.pos]; {
+		case c == '"' || c == '\'' || c == '`':
+			s.pos = s.skipString(s.pos+1, c)
+		case strings.HasPrefix(s.src[s.pos:], "//"):
+			end := strings.IndexByte(s.src[s.pos:], '\n')
+			if end == -1 {
+				end = len(s.src) - s.pos
+			}
+			s.pos += end
+		case strings.HasPrefix(s.src[s.pos:], "/*"):
+			end := strings.Index(s.src[s.pos+2:], "*/")
+			if end == -1 {
+				end = len(s.src) - s.pos - 4
+			}
+			s.pos += end + 4
+		default:
+			if c == '{' {
+				s.depth++
+			} else if c == '}' && s.depth > 0 {
+				s.depth--
+			}
+			s.pos++
+		}
+	}
+	return s.depth, s.pos == offset
+}
+
+// skipString returns the position after the string literal whose content
+// starts at pos.
+func (s *jsScanner) skipString(pos int, quote byte) int {
+	for pos < len(s.src) {
+		switch s.src[pos] {
+		case '\\':
+			pos += 2
+			continue
+		case quote:
+			return pos + 1
+		}
+		pos++
+	}
+	return len(s.src)
+}
+
+// extractJSONGlobal extracts a JSON document as a global named name
+func extractJSONGlobal(name, data string, globals map[string]string) {
+	data = strings.TrimSpace(data)
+	if !json.Valid([]byte(data)) {
+		return
+	}
+	parser := &jsLiteralParser{src: data, globals: globals}
+	parser.parseValue(name, 0)
+}
+
+// jsLiteralParser is a tolerant parser for javascript literals.
+//
+// It understands strings, numbers, booleans, null, arrays and object
+// literals, and gives up on anything else, recording the path as existing.
+type jsLiteralParser struct {
+	src     string
+	pos     int
+	globals map[string]string
+}
+
+// set records a value for a path unless the global limit is reached
+func (p *jsLiteralParser) set(path, value string) {
+	if len(p.globals) >= maxJSGlobals {
+		return
+	}
+	if len(value) > maxJSValueLength {
+		value = value[:maxJSValueLength]
+	}
+	p.globals[path] = value
+}
+
+// setParents records the parents of a dotted path as existing
+func (p *jsLiteralParser) setParents(path string) {
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if _, ok := p.globals[path[:i]]; !ok {
+			p.set(path[:i], "")
+		}
+	}
+}
+
+// parseValue parses the value at the current position and records it
+func (p *jsLiteralParser) parseValue(path string, depth int) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return
+	}
+
+	switch c := p.src[p.pos]; {
+	case c == '"' || c == '\'' || c == '`':
+		value, ok := p.parseString()
+		if !ok {
+			p.set(path, "")
+			return
+		}
+		p.set(path, value)
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		p.set(path, p.parseNumber())
+	case c == '{':
+		p.set(path, "")
+		if depth >= maxJSDepth {
+			p.skipBalanced()
+			return
+		}
+		p.parseObject(path, depth)
+	case c == '[':
+		p.set(path, "")
+		p.skipBalanced()
+	default:
+		identifier := p.parseIdentifier()
+		switch identifier {
+		case "true", "false", "null":
+			p.set(path, identifier)
+		default:
+			// Any other expression only tells us that the property exists
+			p.set(path, "")
+		}
+	}
+}
+
+// parseObject parses an object literal, recording each property
+func (p *jsLiteralParser) parseObject(path string, depth int) {
+	p.pos++ // skip {
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return
+		}
+		if p.src[p.pos] == '}' {
+			p.pos++
+			return
+		}
+
+		var key string
+		switch c := p.src[p.pos]; {
+		case c == '"' || c == '\'':
+			value, ok := p.parseString()
+			if !ok {
+				return
+			}
+			key = value
+		case c >= '0' && c <= '9':
+			key = p.parseNumber()
+		default:
+			key = p.parseIdentifier()
+		}
+		if key == "" {
+			p.skipUntilObjectEnd()
+			return
+		}
+
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return
+		}
+		switch p.src[p.pos] {
+		case ':':
+			p.pos++
+			p.parseValue(path+"."+key, depth+1)
+		case ',', '}':
+			// Shorthand property such as {a, b}
+			p.set(path+"."+key, "")
+		default:
+			// Methods, getters and computed keys are not supported
+			p.skipUntilObjectEnd()
+			return
+		}
+
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		}
+	}
+}
+
+// parseString parses a quoted string literal and returns its value
+func (p *jsLiteralParser) parseString() (string, bool) {
+	quote := p.src[p.pos]
+	p.pos++
+
+	builder := &strings.Builder{}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return builder.String(), true
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			switch escaped := p.src[p.pos]; escaped {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				builder.WriteByte(escaped)
+			}
+		case c == '\n' && quote != '`':
+			return "", false
+		case c == '$' && quote == '`' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '{':
+			// Template literals with substitutions are not literals
+			p.skipString(quote)
+			return "", false
+		default:
+			if builder.Len() < maxJSValueLength {
+				builder.WriteByte(c)
+			}
+		}
+		p.pos++
+	}
+	return "", false
+}
+
+// parseNumber parses a numeric token
+func (p *jsLiteralParser) parseNumber() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if (c >= '0' && c <= '9') || c == '.' || c == '-' || c == '+' || c == 'e' || c == 'E' || c == 'x' || c == '_' ||
+			(c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	number := p.src[start:p.pos]
+	if value, err := strconv.ParseFloat(number, 64); err == nil && !strings.ContainsAny(number, "xX_") {
+		// Normalize numbers so that 1.0 and 1 are matched the same way
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return number
+}
+
+// parseIdentifier parses an identifier token
+func (p *jsLiteralParser) parseIdentifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80 {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+// skipSpace skips whitespace and comments
+func (p *jsLiteralParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			end := strings.IndexByte(p.src[p.pos:], '\n')
+			if end == -1 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 1
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			end := strings.Index(p.src[p.pos+2:], "*/")
+			if end == -1 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+// skipString skips a string literal starting at the current position
+func (p *jsLiteralParser) skipString(quote byte) {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '\\' {
+			p.pos += 2
+			continue
+		}
+		if c == quote {
+			p.pos++
+			return
+		}
+		p.pos++
+	}
+}
+
+// skipBalanced skips a bracketed expression starting at the current position
+func (p *jsLiteralParser) skipBalanced() {
+	var depth int
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case '"', '\'', '`':
+			p.pos++
+			p.skipString(c)
+			continue
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			depth--
+			if depth <= 0 {
+				p.pos++
+				return
+			}
+		}
+		p.pos++
+	}
+}
+
+// skipUntilObjectEnd skips the remaining properties of the current object
+func (p *jsLiteralParser) skipUntilObjectEnd() {
+	depth := 1
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case '"', '\'', '`':
+			p.pos++
+			p.skipString(c)
+			continue
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			depth--
+			if depth == 0 {
+				p.pos++
+				return
+			}
+		}
+		p.pos++
+	}
+}
@@ -0,0 +1,71 @@
+package wappalyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractJSGlobals(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected map[string]string
+	}{
+		{
+			name:     "var",
+			script:   `var Foo = "1.2.3";`,
+			expected: map[string]string{"Foo": "1.2.3"},
+		},
+		{
+			name:   "window-object",
+			script: `window.Shop = {config: {version: '2.0', debug: false}, "name": "test", items: [1, 2]};`,
+			expected: map[string]string{
+				"Shop":                "",
+				"Shop.config":         "",
+				"Shop.config.version": "2.0",
+				"Shop.config.debug":   "false",
+				"Shop.name":           "test",
+				"Shop.items":          "",
+			},
+		},
+		{
+			name:     "member",
+			script:   `App.meta.build = 42; if (a == b) {} x => y; this.y = 1; foo().z = 2;`,
+			expected: map[string]string{"App": "", "App.meta": "", "App.meta.build": "42"},
+		},
+		{
+			name:     "local",
+			script:   `(function() { var local = 1; config.debug = true; window.App = {version: "1.0"}; if (x) { self.Other = 2; } })();`,
+			expected: map[string]string{"App": "", "App.version": "1.0", "Other": "2"},
+		},
+		{
+			name:     "string",
+			script:   `var a = "b = 1"; // c = 2` + "\n" + `/* d = 3 */ var e = '{'; f = 4;`,
+			expected: map[string]string{"a": "b = 1", "e": "{", "f": "4"},
+		},
+		{
+			name:     "expression",
+			script:   `const client = createClient({id: 1});`,
+			expected: map[string]string{"client": ""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			globals := make(map[string]string)
+			extractJSGlobals(test.script, globals)
+			require.Equal(t, test.expected, globals, "could not extract correct globals")
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		globals := make(map[string]string)
+		extractJSONGlobal("__NEXT_DATA__", `{"buildId": "abc", "props": {"page": 1}}`, globals)
+		require.Equal(t, map[string]string{
+			"__NEXT_DATA__":            "",
+			"__NEXT_DATA__.buildId":    "abc",
+			"__NEXT_DATA__.props":      "",
+			"__NEXT_DATA__.props.page": "1",
+		}, globals, "could not extract correct json globals")
+	})
+}
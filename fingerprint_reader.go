@@ -0,0 +1,367 @@
+package wappalyzer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// defaultMaxBodySize is the maximum number of body bytes read by
+	// FingerprintReader when not configured with WithMaxBodySize.
+	defaultMaxBodySize = 10 * 1024 * 1024
+	// maxDocumentSize is the number of document bytes kept in the tree
+	// the dom rules are checked against by FingerprintReader. It is also
+	// the maximum size of a single token, such as an inline script.
+	maxDocumentSize = 1024 * 1024
+	// htmlWindowSize is the size of the window the html patterns are
+	// matched against while streaming the body.
+	htmlWindowSize = 256 * 1024
+	// htmlWindowOverlap is the number of bytes shared by consecutive windows
+	// so that matches crossing a window boundary are not missed.
+	htmlWindowOverlap = 4 * 1024
+)
+
+// FingerprintReader identifies technologies on a target, based on the
+// received response headers and a body read incrementally from a reader.
+//
+// The body is tokenized while it is being read, so it can be used to
+// fingerprint a response while it is downloaded. At most the configured
+// maximum body size is read (see WithMaxBodySize). The html patterns are
+// matched over a sliding window of the body instead of the whole body, so
+// matches longer than the window overlap (4 KiB) crossing a window boundary
+// are not found.
+//
+// The meta, script, js and css rules are checked against the elements as
+// they are read. The dom rules are checked against the first MiB of the
+// document, kept as a tree built from the tokens, so the memory used is
+// bounded whatever the body size. See documentStream for how the tree
+// differs from the one of Fingerprint.
+func (s *Wappalyze) FingerprintReader(headers map[string][]string, body io.Reader) (map[string]struct{}, error) {
+	detections, err := s.FingerprintReaderDetailed(headers, body)
+	if err != nil {
+		return nil, err
+	}
+	return detectionsToValues(detections), nil
+}
+
+// FingerprintReaderDetailed is like FingerprintReader but returns the
+// detected technologies along with their metadata, as FingerprintDetailed.
+func (s *Wappalyze) FingerprintReaderDetailed(headers map[string][]string, body io.Reader) ([]Detection, error) {
+	return s.FingerprintReaderContext(context.Background(), headers, body)
+}
+
+// FingerprintReaderContext is like FingerprintReaderDetailed but stops
+// once the context is done, returning a *CancelledError. The context is
+// also checked before every read from the body.
+func (s *Wappalyze) FingerprintReaderContext(ctx context.Context, headers map[string][]string, body io.Reader) ([]Detection, error) {
+	normalizedHeaders := s.normalizeHeaders(headers)
+	uniqueFingerprints, err := s.fingerprintHeaders(ctx, normalizedHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		technologies, err := s.checkReader(ctx, body)
+		if err != nil {
+			return nil, err
+		}
+		for _, app := range technologies {
+			uniqueFingerprints.setMatch(app)
+		}
+	}
+	return s.detections(s.fingerprints.resolve(uniqueFingerprints)), nil
+}
+
+// checkReader tokenizes the body while it is read, up to the maximum body
+// size, checking the elements of the document as they are closed and
+// matching the html patterns over a sliding window of the body. The dom
+// rules are checked at the end against the elements kept in the tree.
+func (s *Wappalyze) checkReader(ctx context.Context, body io.Reader) ([]matchPartResult, error) {
+	maxBodySize := s.maxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+
+	window := &htmlWindow{
+		fingerprints:   s.fingerprints,
+		recordEvidence: s.evidence,
+		matched:        make(map[string][]windowMatch),
+	}
+	reader := io.TeeReader(&contextReader{ctx: ctx, reader: io.LimitReader(body, maxBodySize)}, window)
+
+	tokenizer := html.NewTokenizer(reader)
+	tokenizer.SetMaxBuf(maxDocumentSize)
+	stream := newDocumentStream()
+	checker := s.newDocumentChecker()
+	for tokenType := tokenizer.Next(); tokenType != html.ErrorToken; tokenType = tokenizer.Next() {
+		for _, element := range stream.add(tokenizer, tokenType) {
+			if err := checker.checkElement(ctx, element); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := tokenizer.Err()
+	switch {
+	case errors.Is(err, io.EOF):
+		err = nil
+	case errors.Is(err, html.ErrBufferExceeded):
+		// The rest of the body is only matched by the window
+		_, err = io.Copy(io.Discard, reader)
+	}
+	if err != nil {
+		if cancelled := checkContext(ctx, htmlPart); cancelled != nil {
+			return nil, cancelled
+		}
+		return nil, fmt.Errorf("could not read body: %w", err)
+	}
+	for _, element := range stream.close() {
+		if err := checker.checkElement(ctx, element); err != nil {
+			return nil, err
+		}
+	}
+	window.flush()
+
+	technologies, err := checker.finish(ctx, collectElements(stream.document))
+	if err != nil {
+		return nil, err
+	}
+	return append(window.results(), technologies...), nil
+}
+
+// voidElements are the elements without end tag
+var voidElements = map[string]struct{}{
+	"area": {}, "base": {}, "br": {}, "col": {}, "embed": {}, "hr": {}, "img": {}, "input": {},
+	"keygen": {}, "link": {}, "meta": {}, "param": {}, "source": {}, "track": {}, "wbr": {},
+}
+
+// impliedEndTags maps the elements closing the open elements of the
+// given tags when they are opened, such as a <li> closing an open <li>.
+var impliedEndTags = map[string][]string{
+	"p":      {"p"},
+	"li":     {"li"},
+	"dt":     {"dt", "dd"},
+	"dd":     {"dt", "dd"},
+	"option": {"option"},
+	"tr":     {"tr", "td", "th"},
+	"td":     {"td", "th"},
+	"th":     {"td", "th"},
+}
+
+// documentStream builds the tree of a document from its tokens.
+//
+// Unlike html.Parse it does not implement the HTML tree construction
+// rules: elements are nested as their tags are, only the end tags of the
+// void elements and of a few elements such as <p> and <li> are implied,
+// and missing <html>, <head> and <body> elements are not added.
+//
+// Elements are kept in the tree until it holds maxDocumentSize bytes of
+// tokens. Later elements are only kept, along with their text for script
+// and style elements, until they are closed.
+type documentStream struct {
+	document *html.Node
+	// open contains the open elements, innermost last
+	open []*html.Node
+	// size is the number of token bytes kept in the tree
+	size int
+	// closed contains the elements closed by the last token
+	closed []*html.Node
+}
+
+// newDocumentStream creates a new document stream
+func newDocumentStream() *documentStream {
+	return &documentStream{document: &html.Node{Type: html.DocumentNode}}
+}
+
+// add adds the current token of the tokenizer to the document, returning
+// the elements it closed, which are complete.
+func (d *documentStream) add(tokenizer *html.Tokenizer, tokenType html.TokenType) []*html.Node {
+	d.closed = d.closed[:0]
+	size := len(tokenizer.Raw())
+
+	switch tokenType {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		token := tokenizer.Token()
+		if tags, ok := impliedEndTags[token.Data]; ok {
+			for len(d.open) > 0 && slices.Contains(tags, d.open[len(d.open)-1].Data) {
+				d.pop()
+			}
+		}
+
+		element := &html.Node{Type: html.ElementNode, DataAtom: token.DataAtom, Data: token.Data, Attr: token.Attr}
+		d.appendChild(element, size)
+		if _, void := voidElements[token.Data]; void || tokenType == html.SelfClosingTagToken {
+			d.closed = append(d.closed, element)
+			break
+		}
+		d.open = append(d.open, element)
+	case html.EndTagToken:
+		name, _ := tokenizer.TagName()
+		for i := len(d.open) - 1; i >= 0; i-- {
+			if d.open[i].Data != string(name) {
+				continue
+			}
+			for len(d.open) > i {
+				d.pop()
+			}
+			break
+		}
+	case html.TextToken:
+		// Only the text of script and style elements is kept outside the tree
+		if d.size >= maxDocumentSize {
+			if len(d.open) == 0 {
+				break
+			}
+			if parent := d.open[len(d.open)-1]; parent.Data != "script" && parent.Data != "style" {
+				break
+			}
+		}
+		d.appendChild(&html.Node{Type: html.TextNode, Data: string(tokenizer.Text())}, size)
+	}
+	return d.closed
+}
+
+// appendChild appends a node to the innermost open element, or to the
+// document. Elements added once the tree is full are not kept in it.
+func (d *documentStream) appendChild(node *html.Node, size int) {
+	parent := d.document
+	if len(d.open) > 0 {
+		parent = d.open[len(d.open)-1]
+	}
+	switch {
+	case parent != d.document && parent.Parent == nil:
+		// The parent is not in the tree, only its text is kept until it is closed
+		if node.Type == html.TextNode {
+			parent.AppendChild(node)
+		}
+		return
+	case node.Type == html.ElementNode && d.size >= maxDocumentSize:
+		return
+	}
+	d.size += size
+	parent.AppendChild(node)
+}
+
+// pop closes the innermost open element
+func (d *documentStream) pop() {
+	element := d.open[len(d.open)-1]
+	d.open = d.open[:len(d.open)-1]
+	d.closed = append(d.closed, element)
+}
+
+// close closes all the open elements at the end of the document,
+// returning them.
+func (d *documentStream) close() []*html.Node {
+	d.closed = d.closed[:0]
+	for len(d.open) > 0 {
+		d.pop()
+	}
+	return d.closed
+}
+
+// htmlWindow is a writer matching the html patterns over a sliding
+// window of the bytes written to it. The html patterns matched in any
+// window are combined into a single result per app, as if the patterns
+// were matched against the whole body.
+type htmlWindow struct {
+	fingerprints *CompiledFingerprints
+	// recordEvidence indicates whether to build the evidence of the matches
+	recordEvidence bool
+	buffer         []byte
+	// pending is the number of bytes of the buffer not matched yet
+	pending int
+
+	// matched contains the matches of the html patterns of each app,
+	// by position of the pattern.
+	matched map[string][]windowMatch
+}
+
+// windowMatch is the first match of an html pattern in a window
+type windowMatch struct {
+	matched bool
+	version string
+	match   string
+}
+
+// Write appends data to the window, matching every full window
+func (w *htmlWindow) Write(data []byte) (int, error) {
+	written := len(data)
+	for len(data) > 0 {
+		if w.buffer == nil {
+			w.buffer = make([]byte, 0, htmlWindowSize)
+		}
+
+		chunk := min(htmlWindowSize-len(w.buffer), len(data))
+		w.buffer = append(w.buffer, data[:chunk]...)
+		w.pending += chunk
+		data = data[chunk:]
+
+		if len(w.buffer) == htmlWindowSize {
+			w.flush()
+			// Keep the end of the window for matches crossing the boundary
+			w.buffer = w.buffer[:copy(w.buffer, w.buffer[htmlWindowSize-htmlWindowOverlap:])]
+		}
+	}
+	return written, nil
+}
+
+// flush matches the window if it contains bytes not matched yet
+func (w *htmlWindow) flush() {
+	if w.pending == 0 {
+		return
+	}
+	w.pending = 0
+
+	data := string(bytes.ToLower(w.buffer))
+	for _, app := range w.fingerprints.getPrefilter(htmlPart).candidates(data) {
+		patterns := w.fingerprints.Apps[app].html
+		matches, ok := w.matched[app]
+		if !ok {
+			matches = make([]windowMatch, len(patterns))
+		}
+
+		var matched bool
+		for i, pattern := range patterns {
+			if matches[i].matched {
+				continue
+			}
+			if valid, version, match := pattern.evaluate(data); valid {
+				matched = true
+				matches[i] = windowMatch{matched: true, version: version, match: match}
+			}
+		}
+		if matched {
+			w.matched[app] = matches
+		}
+	}
+}
+
+// results returns the html matches of all the windows, one per app, in
+// the same way as matchString does for a whole body.
+func (w *htmlWindow) results() []matchPartResult {
+	technologies := make([]matchPartResult, 0, len(w.matched))
+	for _, app := range sortedKeys(w.matched) {
+		result := matchPartResult{application: app, confidence: 100}
+		for i, pattern := range w.fingerprints.Apps[app].html {
+			match := w.matched[app][i]
+			if !match.matched {
+				continue
+			}
+			if result.version == "" && match.version != "" {
+				result.version = match.version
+			}
+			result.confidence = pattern.Confidence
+			if w.recordEvidence {
+				result.evidence = append(result.evidence, newEvidence(htmlPart, "", pattern, match.match))
+			}
+		}
+		technologies = append(technologies, result)
+	}
+	return technologies
+}
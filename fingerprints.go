@@ -2,6 +2,9 @@ package wappalyzer
 
 import (
 	"fmt"
+	"sort"
+
+	"github.com/andybalholm/cascadia"
 )
 
 // Fingerprints contains a map of fingerprints for tech detection
@@ -12,22 +15,25 @@ type Fingerprints struct {
 
 // Fingerprint is a single piece of information about a tech validated and normalized
 type Fingerprint struct {
-	Cats        []int                             `json:"cats"`
-	CSS         []string                          `json:"css"`
-	Cookies     map[string]string                 `json:"cookies"`
-	Dom         map[string]map[string]interface{} `json:"dom"`
-	JS          map[string]string                 `json:"js"`
-	Headers     map[string]string                 `json:"headers"`
-	HTML        []string                          `json:"html"`
-	Script      []string                          `json:"scripts"`
-	ScriptSrc   []string                          `json:"scriptSrc"`
-	Meta        map[string][]string               `json:"meta"`
-	Implies     []string                          `json:"implies"`
-	Description string                            `json:"description"`
-	Website     string                            `json:"website"`
-	CPE         string                            `json:"cpe"`
-	Icon        string                            `json:"icon"`
-	Browser     *BrowserDetection                 `json:"browser,omitempty"` // NEW: Browser-based detection
+	Cats             []int                             `json:"cats"`
+	CSS              []string                          `json:"css"`
+	Cookies          map[string]string                 `json:"cookies"`
+	Dom              map[string]map[string]interface{} `json:"dom"`
+	JS               map[string]string                 `json:"js"`
+	Headers          map[string]string                 `json:"headers"`
+	HTML             []string                          `json:"html"`
+	Script           []string                          `json:"scripts"`
+	ScriptSrc        []string                          `json:"scriptSrc"`
+	Meta             map[string][]string               `json:"meta"`
+	Implies          []string                          `json:"implies"`
+	Excludes         []string                          `json:"excludes"`
+	Requires         []string                          `json:"requires"`
+	RequiresCategory []int                             `json:"requiresCategory"`
+	Description      string                            `json:"description"`
+	Website          string                            `json:"website"`
+	CPE              string                            `json:"cpe"`
+	Icon             string                            `json:"icon"`
+	Browser          *BrowserDetection                 `json:"browser,omitempty"` // NEW: Browser-based detection
 }
 
 // BrowserDetection defines browser-based detection rules
@@ -56,6 +62,9 @@ type VersionExtraction struct {
 type CompiledFingerprints struct {
 	// Apps is organized as <name, fingerprint>
 	Apps map[string]*CompiledFingerprint
+
+	// indexes contains the lookup indexes built from the apps
+	indexes fingerprintIndexes
 }
 
 // CompiledFingerprint contains the compiled fingerprints from the tech json
@@ -63,7 +72,13 @@ type CompiledFingerprint struct {
 	// cats contain categories that are implicit with this tech
 	cats []int
 	// implies contains technologies that are implicit with this tech
-	implies []string
+	implies []impliedTechnology
+	// excludes contains technologies that are removed when this tech is detected
+	excludes []string
+	// requires contains technologies required for this tech to be detected
+	requires []string
+	// requiresCategory contains categories required for this tech to be detected
+	requiresCategory []int
 	// description contains fingerprint description
 	description string
 	// website contains a URL associated with the fingerprint
@@ -76,6 +91,11 @@ type CompiledFingerprint struct {
 	js map[string]*ParsedPattern
 	// dom contains fingerprints for the target dom
 	dom map[string]map[string]*ParsedPattern
+	// domSelectors contains the compiled css selectors for the dom fingerprints
+	domSelectors map[string]cascadia.SelectorGroup
+	// domKeys contains the keys of the elements each dom selector can
+	// match, see selectorKeys. Selectors without keys match any element.
+	domKeys map[string][]string
 	// headers contains fingerprints for target headers
 	headers map[string]*ParsedPattern
 	// html contains fingerprints for the target HTML
@@ -86,8 +106,12 @@ type CompiledFingerprint struct {
 	scriptSrc []*ParsedPattern
 	// meta contains fingerprints for meta tags
 	meta map[string][]*ParsedPattern
+	// css contains fingerprints for stylesheets
+	css []*ParsedPattern
 	// cpe contains the cpe for a fingerpritn
 	cpe string
+	// diagnostics contains the rules rejected when compiling the fingerprint
+	diagnostics []Diagnostic
 }
 
 func (f *CompiledFingerprint) GetJSRules() map[string]*ParsedPattern {
@@ -123,349 +147,348 @@ const (
 	htmlPart
 	scriptPart
 	metaPart
+	scriptsPart
+	domPart
+	cssPart
 )
 
-// loadPatterns loads the fingerprint patterns and compiles regexes
-func compileFingerprint(fingerprint *Fingerprint) *CompiledFingerprint {
+// String returns the name of the part as used in evidence
+func (p part) String() string {
+	switch p {
+	case cookiesPart:
+		return "cookie"
+	case jsPart:
+		return "js"
+	case headersPart:
+		return "header"
+	case htmlPart:
+		return "html"
+	case scriptPart:
+		return "scriptSrc"
+	case metaPart:
+		return "meta"
+	case scriptsPart:
+		return "scripts"
+	case domPart:
+		return "dom"
+	case cssPart:
+		return "css"
+	}
+	return "unknown"
+}
+
+// compileFingerprint loads the fingerprint patterns of an app. Their regexes
+// are compiled on first evaluation, see compilePatterns to compile them upfront.
+func compileFingerprint(app string, fingerprint *Fingerprint) *CompiledFingerprint {
 	compiled := &CompiledFingerprint{
-		cats:        fingerprint.Cats,
-		implies:     fingerprint.Implies,
-		description: fingerprint.Description,
-		website:     fingerprint.Website,
-		icon:        fingerprint.Icon,
-		dom:         make(map[string]map[string]*ParsedPattern),
-		cookies:     make(map[string]*ParsedPattern),
-		js:          make(map[string]*ParsedPattern),
-		headers:     make(map[string]*ParsedPattern),
-		html:        make([]*ParsedPattern, 0, len(fingerprint.HTML)),
-		script:      make([]*ParsedPattern, 0, len(fingerprint.Script)),
-		scriptSrc:   make([]*ParsedPattern, 0, len(fingerprint.ScriptSrc)),
-		meta:        make(map[string][]*ParsedPattern),
-		cpe:         fingerprint.CPE,
+		cats:             fingerprint.Cats,
+		implies:          parseImplies(fingerprint.Implies),
+		excludes:         fingerprint.Excludes,
+		requires:         fingerprint.Requires,
+		description:      fingerprint.Description,
+		website:          fingerprint.Website,
+		icon:             fingerprint.Icon,
+		dom:              make(map[string]map[string]*ParsedPattern),
+		domSelectors:     make(map[string]cascadia.SelectorGroup),
+		domKeys:          make(map[string][]string),
+		cookies:          make(map[string]*ParsedPattern),
+		js:               make(map[string]*ParsedPattern),
+		headers:          make(map[string]*ParsedPattern),
+		html:             make([]*ParsedPattern, 0, len(fingerprint.HTML)),
+		script:           make([]*ParsedPattern, 0, len(fingerprint.Script)),
+		scriptSrc:        make([]*ParsedPattern, 0, len(fingerprint.ScriptSrc)),
+		meta:             make(map[string][]*ParsedPattern),
+		css:              make([]*ParsedPattern, 0, len(fingerprint.CSS)),
+		cpe:              fingerprint.CPE,
+		requiresCategory: fingerprint.RequiresCategory,
 	}
 
 	for dom, patterns := range fingerprint.Dom {
 		compiled.dom[dom] = make(map[string]*ParsedPattern)
 
+		selector, err := cascadia.ParseGroup(dom)
+		if err != nil {
+			compiled.diagnostics = append(compiled.diagnostics, newDiagnostic(app, domPart, "", dom, fmt.Errorf("invalid selector: %w", err)))
+		} else {
+			compiled.domSelectors[dom] = selector
+			if keys, ok := selectorKeys(dom); ok {
+				compiled.domKeys[dom] = keys
+			}
+		}
+
 		for attr, value := range patterns {
 			switch attr {
 			case "exists", "text":
-				pattern, err := ParsePattern(value.(string))
-				if err != nil {
+				str, ok := value.(string)
+				if !ok {
+					compiled.diagnostics = append(compiled.diagnostics, newDiagnostic(app, domPart, dom, fmt.Sprint(value), fmt.Errorf("unsupported %s value type %T", attr, value)))
 					continue
 				}
-				compiled.dom[dom]["main"] = pattern
+				compiled.dom[dom][domTextKey] = parsePattern(str)
 			case "attributes":
 				attrMap, ok := value.(map[string]interface{})
 				if !ok {
+					compiled.diagnostics = append(compiled.diagnostics, newDiagnostic(app, domPart, dom, fmt.Sprint(value), fmt.Errorf("unsupported attributes value type %T", value)))
 					continue
 				}
-				compiled.dom[dom] = make(map[string]*ParsedPattern)
 				for attrName, value := range attrMap {
-					pattern, err := ParsePattern(value.(string))
-					if err != nil {
+					str, ok := value.(string)
+					if !ok {
+						compiled.diagnostics = append(compiled.diagnostics, newDiagnostic(app, domPart, dom+" @"+attrName, fmt.Sprint(value), fmt.Errorf("unsupported attribute value type %T", value)))
 						continue
 					}
-					compiled.dom[dom][attrName] = pattern
+					compiled.dom[dom][attrName] = parsePattern(str)
 				}
 			}
 		}
 	}
 
 	for header, pattern := range fingerprint.Cookies {
-		fingerprint, err := ParsePattern(pattern)
-		if err != nil {
-			continue
-		}
-		compiled.cookies[header] = fingerprint
+		compiled.cookies[header] = parsePattern(pattern)
 	}
 
 	for k, pattern := range fingerprint.JS {
-		fingerprint, err := ParsePattern(pattern)
-		if err != nil {
-			continue
-		}
-		compiled.js[k] = fingerprint
+		compiled.js[k] = parsePattern(pattern)
 	}
 
 	for header, pattern := range fingerprint.Headers {
-		fingerprint, err := ParsePattern(pattern)
-		if err != nil {
-			continue
-		}
-		compiled.headers[header] = fingerprint
+		compiled.headers[header] = parsePattern(pattern)
 	}
 
 	for _, pattern := range fingerprint.HTML {
-		fingerprint, err := ParsePattern(pattern)
-		if err != nil {
-			continue
-		}
-		compiled.html = append(compiled.html, fingerprint)
+		compiled.html = append(compiled.html, parsePattern(pattern))
 	}
 
 	for _, pattern := range fingerprint.Script {
-		fingerprint, err := ParsePattern(pattern)
-		if err != nil {
-			continue
-		}
-		compiled.script = append(compiled.script, fingerprint)
+		compiled.script = append(compiled.script, parsePattern(pattern))
 	}
 
 	for _, pattern := range fingerprint.ScriptSrc {
-		fingerprint, err := ParsePattern(pattern)
-		if err != nil {
-			continue
-		}
-		compiled.scriptSrc = append(compiled.scriptSrc, fingerprint)
+		compiled.scriptSrc = append(compiled.scriptSrc, parsePattern(pattern))
 	}
 
-	for meta, patterns := range fingerprint.Meta {
-		var compiledList []*ParsedPattern
+	for _, pattern := range fingerprint.CSS {
+		compiled.css = append(compiled.css, parsePattern(pattern))
+	}
 
+	for meta, patterns := range fingerprint.Meta {
+		compiledList := make([]*ParsedPattern, 0, len(patterns))
 		for _, pattern := range patterns {
-			fingerprint, err := ParsePattern(pattern)
-			if err != nil {
-				continue
-			}
-			compiledList = append(compiledList, fingerprint)
+			compiledList = append(compiledList, parsePattern(pattern))
 		}
 		compiled.meta[meta] = compiledList
 	}
 	return compiled
 }
 
-// matchString matches a string for the fingerprints
-func (f *CompiledFingerprints) matchString(data string, part part) []matchPartResult {
-	var matched bool
-	var technologies []matchPartResult
-
-	for app, fingerprint := range f.Apps {
-		var version string
-		confidence := 100
-
-		switch part {
-		case jsPart:
-			for _, pattern := range fingerprint.js {
-				if valid, versionString := pattern.Evaluate(data); valid {
-					matched = true
-					if version == "" && versionString != "" {
-						version = versionString
-					}
-					confidence = pattern.Confidence
-				}
-			}
-		case scriptPart:
-			for _, pattern := range fingerprint.scriptSrc {
-				if valid, versionString := pattern.Evaluate(data); valid {
-					matched = true
-					if version == "" && versionString != "" {
-						version = versionString
-					}
-					confidence = pattern.Confidence
-				}
-			}
-		case htmlPart:
-			for _, pattern := range fingerprint.html {
-				if valid, versionString := pattern.Evaluate(data); valid {
-					matched = true
-					if version == "" && versionString != "" {
-						version = versionString
-					}
-					confidence = pattern.Confidence
-				}
+// forEachPattern calls fn with every pattern of the fingerprint along with
+// its part and, for the parts matched by key, its key. Dom patterns are
+// keyed by selector, and by attribute for the attribute patterns.
+func (f *CompiledFingerprint) forEachPattern(fn func(part part, key string, pattern *ParsedPattern)) {
+	for _, dom := range sortedKeys(f.dom) {
+		for _, attr := range sortedKeys(f.dom[dom]) {
+			key := dom
+			if attr != domTextKey {
+				key = dom + " @" + attr
 			}
+			fn(domPart, key, f.dom[dom][attr])
 		}
-
-		// If no match, continue with the next fingerprint
-		if !matched {
-			continue
+	}
+	for _, key := range sortedKeys(f.cookies) {
+		fn(cookiesPart, key, f.cookies[key])
+	}
+	for _, key := range sortedKeys(f.js) {
+		fn(jsPart, key, f.js[key])
+	}
+	for _, key := range sortedKeys(f.headers) {
+		fn(headersPart, key, f.headers[key])
+	}
+	for _, key := range sortedKeys(f.meta) {
+		for _, pattern := range f.meta[key] {
+			fn(metaPart, key, pattern)
+		}
+	}
+	for _, part := range prefilterParts {
+		for _, pattern := range f.partPatterns(part) {
+			fn(part, "", pattern)
 		}
+	}
+}
 
-		// Append the technologies as well as implied ones
-		technologies = append(technologies, matchPartResult{
-			application: app,
-			version:     version,
-			confidence:  confidence,
-		})
-		if len(fingerprint.implies) > 0 {
-			for _, implies := range fingerprint.implies {
-				technologies = append(technologies, matchPartResult{
-					application: implies,
-					confidence:  confidence,
-				})
-			}
+// matchString matches a string for the fingerprints. Parts with a
+// prefilter only evaluate the apps that can possibly match the string.
+// The evidence of the matches is only built with recordEvidence.
+func (f *CompiledFingerprints) matchString(data string, part part, recordEvidence bool) []matchPartResult {
+	filter := f.getPrefilter(part)
+	if filter == nil {
+		return f.matchStringAll(data, part, recordEvidence)
+	}
+
+	var technologies []matchPartResult
+	for _, app := range filter.candidates(data) {
+		if result, ok := matchStringApp(app, f.Apps[app], data, part, recordEvidence); ok {
+			technologies = append(technologies, result)
 		}
-		matched = false
 	}
 	return technologies
 }
 
-// matchKeyValue matches a key-value store map for the fingerprints
-func (f *CompiledFingerprints) matchKeyValueString(key, value string, part part) []matchPartResult {
-	var matched bool
+// matchStringAll matches a string against every fingerprint
+func (f *CompiledFingerprints) matchStringAll(data string, part part, recordEvidence bool) []matchPartResult {
 	var technologies []matchPartResult
-
 	for app, fingerprint := range f.Apps {
-		var version string
-		confidence := 100
+		if result, ok := matchStringApp(app, fingerprint, data, part, recordEvidence); ok {
+			technologies = append(technologies, result)
+		}
+	}
+	return technologies
+}
 
-		switch part {
-		case cookiesPart:
-			for data, pattern := range fingerprint.cookies {
-				if data != key {
-					continue
+// matchStringApp matches a string for the fingerprint of a single app
+func matchStringApp(app string, fingerprint *CompiledFingerprint, data string, part part, recordEvidence bool) (matchPartResult, bool) {
+	var matched bool
+	var version string
+	var evidence []Evidence
+	confidence := 100
+
+	switch part {
+	case jsPart:
+		for key, pattern := range fingerprint.js {
+			if valid, versionString, match := pattern.evaluate(data); valid {
+				matched = true
+				if version == "" && versionString != "" {
+					version = versionString
 				}
-
-				if valid, versionString := pattern.Evaluate(value); valid {
-					matched = true
-					if version == "" && versionString != "" {
-						version = versionString
-					}
-					confidence = pattern.Confidence
-					break
+				confidence = pattern.Confidence
+				if recordEvidence {
+					evidence = append(evidence, newEvidence(part, key, pattern, match))
 				}
 			}
-		case headersPart:
-			for data, pattern := range fingerprint.headers {
-				if data != key {
-					continue
+		}
+	case scriptPart:
+		for _, pattern := range fingerprint.scriptSrc {
+			if valid, versionString, match := pattern.evaluate(data); valid {
+				matched = true
+				if version == "" && versionString != "" {
+					version = versionString
 				}
-
-				if valid, versionString := pattern.Evaluate(value); valid {
-					matched = true
-					if version == "" && versionString != "" {
-						version = versionString
-					}
-					confidence = pattern.Confidence
-					break
+				confidence = pattern.Confidence
+				if recordEvidence {
+					evidence = append(evidence, newEvidence(part, "", pattern, match))
 				}
 			}
-		case metaPart:
-			for data, patterns := range fingerprint.meta {
-				if data != key {
-					continue
+		}
+	case htmlPart:
+		for _, pattern := range fingerprint.html {
+			if valid, versionString, match := pattern.evaluate(data); valid {
+				matched = true
+				if version == "" && versionString != "" {
+					version = versionString
 				}
-
-				for _, pattern := range patterns {
-					if valid, versionString := pattern.Evaluate(value); valid {
-						matched = true
-						if version == "" && versionString != "" {
-							version = versionString
-						}
-						confidence = pattern.Confidence
-						break
-					}
+				confidence = pattern.Confidence
+				if recordEvidence {
+					evidence = append(evidence, newEvidence(part, "", pattern, match))
 				}
 			}
 		}
-
-		// If no match, continue with the next fingerprint
-		if !matched {
-			continue
+	case scriptsPart:
+		for _, pattern := range fingerprint.script {
+			if valid, versionString, match := pattern.evaluate(data); valid {
+				matched = true
+				if version == "" && versionString != "" {
+					version = versionString
+				}
+				confidence = pattern.Confidence
+				if recordEvidence {
+					evidence = append(evidence, newEvidence(part, "", pattern, match))
+				}
+			}
 		}
-
-		technologies = append(technologies, matchPartResult{
-			application: app,
-			version:     version,
-			confidence:  confidence,
-		})
-		if len(fingerprint.implies) > 0 {
-			for _, implies := range fingerprint.implies {
-				technologies = append(technologies, matchPartResult{
-					application: implies,
-					confidence:  confidence,
-				})
+	case cssPart:
+		for _, pattern := range fingerprint.css {
+			if valid, versionString, match := pattern.evaluate(data); valid {
+				matched = true
+				if version == "" && versionString != "" {
+					version = versionString
+				}
+				confidence = pattern.Confidence
+				if recordEvidence {
+					evidence = append(evidence, newEvidence(part, "", pattern, match))
+				}
 			}
 		}
-		matched = false
 	}
-	return technologies
+
+	if !matched {
+		return matchPartResult{}, false
+	}
+	return matchPartResult{
+		application: app,
+		version:     version,
+		confidence:  confidence,
+		evidence:    evidence,
+	}, true
 }
 
-// matchMapString matches a key-value store map for the fingerprints
-func (f *CompiledFingerprints) matchMapString(keyValue map[string]string, part part) []matchPartResult {
-	var matched bool
+// matchKeyValue matches a key-value store map for the fingerprints
+func (f *CompiledFingerprints) matchKeyValueString(key, value string, part part, recordEvidence bool) []matchPartResult {
+	return f.matchMapString(map[string]string{key: value}, part, recordEvidence)
+}
+
+// matchMapString matches a key-value store map for the fingerprints.
+//
+// Only the apps having rules for the keys present in the map are evaluated,
+// using the inverted key index of the part. Keys are evaluated in sorted
+// order. For cookies and headers the first matching key of an app is used,
+// while meta and js rules accumulate the matches of every key. The
+// evidence of the matches is only built with recordEvidence.
+func (f *CompiledFingerprints) matchMapString(keyValue map[string]string, part part, recordEvidence bool) []matchPartResult {
+	index := f.getKeyIndex(part)
+
+	keys := make([]string, 0, len(keyValue))
+	for key := range keyValue {
+		if _, ok := index[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	firstOnly := part == cookiesPart || part == headersPart
+
 	var technologies []matchPartResult
+	positions := make(map[string]int)
+	for _, key := range keys {
+		value := keyValue[key]
 
-	for app, fingerprint := range f.Apps {
-		var version string
-		confidence := 100
+		for _, entry := range index[key] {
+			position, matched := positions[entry.app]
+			if matched && firstOnly {
+				continue
+			}
 
-		switch part {
-		case cookiesPart:
-			for data, pattern := range fingerprint.cookies {
-				value, ok := keyValue[data]
-				if !ok {
+			for _, pattern := range entry.patterns {
+				valid, versionString, match := pattern.evaluate(value)
+				if !valid {
 					continue
 				}
-				if pattern == nil {
-					matched = true
-				}
-				if valid, versionString := pattern.Evaluate(value); valid {
+				if !matched {
 					matched = true
-					if version == "" && versionString != "" {
-						version = versionString
-					}
-					confidence = pattern.Confidence
-					break
-				}
-			}
-		case headersPart:
-			for data, pattern := range fingerprint.headers {
-				value, ok := keyValue[data]
-				if !ok {
-					continue
+					position = len(technologies)
+					positions[entry.app] = position
+					technologies = append(technologies, matchPartResult{application: entry.app})
 				}
 
-				if valid, versionString := pattern.Evaluate(value); valid {
-					matched = true
-					if version == "" && versionString != "" {
-						version = versionString
-					}
-					confidence = pattern.Confidence
-					break
-				}
-			}
-		case metaPart:
-			for data, patterns := range fingerprint.meta {
-				value, ok := keyValue[data]
-				if !ok {
-					continue
+				technology := &technologies[position]
+				if technology.version == "" && versionString != "" {
+					technology.version = versionString
 				}
-
-				for _, pattern := range patterns {
-					if valid, versionString := pattern.Evaluate(value); valid {
-						matched = true
-						if version == "" && versionString != "" {
-							version = versionString
-						}
-						confidence = pattern.Confidence
-						break
-					}
+				technology.confidence = pattern.Confidence
+				if recordEvidence {
+					technology.evidence = append(technology.evidence, newEvidence(part, entry.key, pattern, match))
 				}
+				break
 			}
 		}
-
-		// If no match, continue with the next fingerprint
-		if !matched {
-			continue
-		}
-
-		technologies = append(technologies, matchPartResult{
-			application: app,
-			version:     version,
-			confidence:  confidence,
-		})
-		if len(fingerprint.implies) > 0 {
-			for _, implies := range fingerprint.implies {
-				technologies = append(technologies, matchPartResult{
-					application: implies,
-					confidence:  confidence,
-				})
-			}
-		}
-		matched = false
 	}
 	return technologies
 }
@@ -478,6 +501,8 @@ func FormatAppVersion(app, version string) string {
 }
 
 // GetFingerprints returns the fingerprint string from wappalyzer
+//
+// Deprecated: Use New or EmbeddedSource to load the embedded fingerprints.
 func GetFingerprints() string {
-	return fingerprints
+	return GetRawFingerprints()
 }
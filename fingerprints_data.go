@@ -3,8 +3,8 @@ package wappalyzer
 import (
 	"embed"
 	"encoding/json"
+	"fmt"
 	"io/fs"
-	"path/filepath"
 	"strconv"
 	"sync"
 )
@@ -13,97 +13,103 @@ var (
 	//go:embed data
 	dataFS embed.FS
 
-	syncOnce          sync.Once
+	// The embedded data is only decoded on first use
+	embeddedOnce sync.Once
+	embeddedApps map[string]*Fingerprint
+	embeddedErr  error
+
+	embeddedHashOnce sync.Once
+	embeddedHash     []byte
+	embeddedHashErr  error
+
+	categoriesOnce    sync.Once
 	categoriesMapping map[int]categoryItem
-	fingerprintsCache string
-	fingerprints      string // Deprecated: Use fingerprintsCache or GetRawFingerprints()
+	categoriesErr     error
+
+	rawFingerprintsOnce sync.Once
+	rawFingerprints     string
 )
 
-func init() {
-	syncOnce.Do(func() {
-		categoriesMapping = make(map[int]categoryItem)
+// loadEmbeddedFingerprints decodes the embedded fingerprints files
+// once, straight into the fingerprint apps.
+//
+// The returned apps are shared, EmbeddedSource returns a copy of them
+// to every loader.
+func loadEmbeddedFingerprints() (map[string]*Fingerprint, error) {
+	embeddedOnce.Do(func() {
+		fingerprintsFS, err := fs.Sub(dataFS, "data/fingerprints")
+		if err != nil {
+			embeddedErr = err
+			return
+		}
+		embeddedApps, embeddedErr = FSSource("embedded", fingerprintsFS).load()
+	})
+	return embeddedApps, embeddedErr
+}
+
+// hashEmbeddedFingerprints returns the content hash of the embedded
+// fingerprints files, computed once.
+func hashEmbeddedFingerprints() ([]byte, error) {
+	embeddedHashOnce.Do(func() {
+		fingerprintsFS, err := fs.Sub(dataFS, "data/fingerprints")
+		if err != nil {
+			embeddedHashErr = err
+			return
+		}
+		embeddedHash, embeddedHashErr = hashFS(fingerprintsFS)
+	})
+	return embeddedHash, embeddedHashErr
+}
 
-		// Load categories
+// loadCategories decodes the embedded categories once
+func loadCategories() (map[int]categoryItem, error) {
+	categoriesOnce.Do(func() {
 		categoriesData, err := dataFS.ReadFile("data/categories/categories.json")
 		if err != nil {
-			panic("failed to load categories: " + err.Error())
+			categoriesErr = fmt.Errorf("could not read categories: %w", err)
+			return
 		}
 
 		var categories map[string]categoryItem
 		if err := json.Unmarshal(categoriesData, &categories); err != nil {
-			panic("failed to parse categories: " + err.Error())
+			categoriesErr = fmt.Errorf("could not parse categories: %w", err)
+			return
 		}
 
+		mapping := make(map[int]categoryItem, len(categories))
 		for category, data := range categories {
 			parsed, _ := strconv.Atoi(category)
-			categoriesMapping[parsed] = data
+			mapping[parsed] = data
 		}
-
-		// Load and merge all fingerprint files
-		fingerprintsCache = loadAllFingerprints()
-		fingerprints = fingerprintsCache // For backward compatibility
+		categoriesMapping = mapping
 	})
+	return categoriesMapping, categoriesErr
 }
 
-func loadAllFingerprints() string {
-	allApps := make(map[string]interface{})
-
-	// Walk through all JSON files in data/fingerprints/
-	err := fs.WalkDir(dataFS, "data/fingerprints", func(path string, d fs.DirEntry, err error) error {
+// GetRawFingerprints returns the embedded fingerprints as a JSON document.
+// It returns an empty string if the embedded fingerprints could not be loaded.
+//
+// Deprecated: Use New or EmbeddedSource to load the embedded fingerprints.
+func GetRawFingerprints() string {
+	rawFingerprintsOnce.Do(func() {
+		apps, err := loadEmbeddedFingerprints()
 		if err != nil {
-			return err
+			return
 		}
-
-		// Skip directories and non-JSON files
-		if d.IsDir() || filepath.Ext(path) != ".json" {
-			return nil
-		}
-
-		// Read the category file
-		data, err := dataFS.ReadFile(path)
+		data, err := json.Marshal(&Fingerprints{Apps: apps})
 		if err != nil {
-			return err
-		}
-
-		// Parse the category file
-		var categoryData struct {
-			Apps map[string]interface{} `json:"apps"`
+			return
 		}
-		if err := json.Unmarshal(data, &categoryData); err != nil {
-			return err
-		}
-
-		// Merge apps into allApps
-		for appName, appData := range categoryData.Apps {
-			allApps[appName] = appData
-		}
-
-		return nil
+		rawFingerprints = string(data)
 	})
-
-	if err != nil {
-		panic("failed to load fingerprints: " + err.Error())
-	}
-
-	// Create the expected format
-	result := map[string]interface{}{
-		"apps": allApps,
-	}
-
-	resultJSON, err := json.Marshal(result)
-	if err != nil {
-		panic("failed to marshal fingerprints: " + err.Error())
-	}
-
-	return string(resultJSON)
-}
-
-func GetRawFingerprints() string {
-	return fingerprintsCache
+	return rawFingerprints
 }
 
+// GetCategoriesMapping returns the embedded categories by ID.
+// It returns nil if the embedded categories could not be loaded.
 func GetCategoriesMapping() map[int]categoryItem {
-	return categoriesMapping
+	mapping, _ := loadCategories()
+	return mapping
 }
 
 type categoryItem struct {
@@ -0,0 +1,79 @@
+package wappalyzer
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRawFingerprints(t *testing.T) {
+	apps, err := loadEmbeddedFingerprints()
+	require.Nil(t, err, "could not load embedded fingerprints")
+
+	var raw Fingerprints
+	err = json.Unmarshal([]byte(GetRawFingerprints()), &raw)
+	require.Nil(t, err, "could not parse raw fingerprints")
+	require.Len(t, raw.Apps, len(apps), "could not get all raw fingerprints")
+}
+
+func TestEmbeddedFingerprintsIsolation(t *testing.T) {
+	first, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+
+	fingerprint := first.GetFingerprints().Apps["Nginx"]
+	require.NotNil(t, fingerprint, "could not get embedded fingerprint")
+	fingerprint.Headers["server"] = "modified"
+	fingerprint.Cats[0] = -1
+	first.GetFingerprints().Apps["Injected App"] = &Fingerprint{}
+
+	second, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+	require.NotEqual(t, "modified", second.GetFingerprints().Apps["Nginx"].Headers["server"], "could modify embedded fingerprints")
+	require.NotEqual(t, -1, second.GetFingerprints().Apps["Nginx"].Cats[0], "could modify embedded fingerprints")
+	require.NotContains(t, second.GetFingerprints().Apps, "Injected App", "could modify embedded fingerprints")
+
+	fingerprints := second.Fingerprint(map[string][]string{"Server": {"nginx/1.18.0"}}, nil)
+	require.Contains(t, fingerprints, "Nginx:1.18.0", "could not match unmodified fingerprint")
+}
+
+// BenchmarkStartup measures the decoding of the embedded fingerprints,
+// without and with the fingerprints cache, and the creation of an
+// instance from them.
+func BenchmarkStartup(b *testing.B) {
+	fingerprintsFS, err := fs.Sub(dataFS, "data/fingerprints")
+	require.Nil(b, err, "could not open embedded fingerprints")
+
+	b.Run("decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := FSSource("embedded", fingerprintsFS).load(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		loader := NewLoader(FSSource("embedded", fingerprintsFS))
+		cachePath := filepath.Join(b.TempDir(), "fingerprints.cache")
+		if _, _, err := loader.LoadCached(cachePath); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := loader.LoadCached(cachePath); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewFromFS(fingerprintsFS); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -3,13 +3,14 @@ module github.com/projectdiscovery/wappalyzergo
 go 1.24.0
 
 require (
+	github.com/andybalholm/cascadia v1.3.3
+	github.com/chromedp/chromedp v0.14.2
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/net v0.49.0
 )
 
 require (
 	github.com/chromedp/cdproto v0.0.0-20250724212937-08a3db8b4327 // indirect
-	github.com/chromedp/chromedp v0.14.2 // indirect
 	github.com/chromedp/sysutil v1.1.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/go-json-experiment/json v0.0.0-20250725192818-e39067aee2d2 // indirect
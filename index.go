@@ -0,0 +1,101 @@
+package wappalyzer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// partCount is the number of parts, used to size the per-part indexes
+const partCount = int(cssPart) + 1
+
+// fingerprintIndexes contains the lookup structures built from the
+// compiled fingerprints to avoid walking every app for every input.
+// Each index is built on the first match of its part.
+type fingerprintIndexes struct {
+	// prefilters contains the literal prefilters of the string parts
+	prefilters    [partCount]*prefilter
+	prefilterOnce [partCount]sync.Once
+	// keys contains the inverted indexes of the key-value parts
+	keys     [partCount]keyIndex
+	keysOnce [partCount]sync.Once
+	// dom contains the dom selectors by the elements they can match
+	dom     *domIndex
+	domOnce sync.Once
+}
+
+// keyIndex maps a header, cookie, meta or js key to the
+// patterns of the apps having rules for it, sorted by app.
+type keyIndex map[string][]keyPatterns
+
+// keyPatterns contains the patterns of an app for a key
+type keyPatterns struct {
+	app string
+	// key is the key as present in the fingerprint
+	key      string
+	patterns []*ParsedPattern
+}
+
+// keyIndexParts are the parts matched with matchMapString that are indexed
+var keyIndexParts = []part{cookiesPart, headersPart, metaPart, jsPart}
+
+// buildIndexes builds all the indexes of the fingerprints if not already
+// built. It must only be called once all the apps are loaded.
+func (f *CompiledFingerprints) buildIndexes() {
+	for _, part := range prefilterParts {
+		f.getPrefilter(part)
+	}
+	for _, part := range keyIndexParts {
+		f.getKeyIndex(part)
+	}
+	f.getDOMIndex()
+}
+
+// getKeyIndex returns the inverted key index of a part, building it if needed
+func (f *CompiledFingerprints) getKeyIndex(part part) keyIndex {
+	f.indexes.keysOnce[part].Do(func() {
+		f.indexes.keys[part] = newKeyIndex(f.Apps, part)
+	})
+	return f.indexes.keys[part]
+}
+
+// newKeyIndex builds the inverted key index for a part of the apps
+func newKeyIndex(apps map[string]*CompiledFingerprint, part part) keyIndex {
+	index := make(keyIndex)
+	for _, app := range sortedKeys(apps) {
+		fingerprint := apps[app]
+
+		switch part {
+		case cookiesPart:
+			for key, pattern := range fingerprint.cookies {
+				index[key] = append(index[key], keyPatterns{app: app, key: key, patterns: []*ParsedPattern{pattern}})
+			}
+		case headersPart:
+			for key, pattern := range fingerprint.headers {
+				index[key] = append(index[key], keyPatterns{app: app, key: key, patterns: []*ParsedPattern{pattern}})
+			}
+		case metaPart:
+			for key, patterns := range fingerprint.meta {
+				index[key] = append(index[key], keyPatterns{app: app, key: key, patterns: patterns})
+			}
+		case jsPart:
+			for key, pattern := range fingerprint.js {
+				// Some keys in the dataset carry stray whitespace
+				trimmed := strings.TrimSpace(key)
+				index[trimmed] = append(index[trimmed], keyPatterns{app: app, key: key, patterns: []*ParsedPattern{pattern}})
+			}
+		}
+	}
+
+	// Entries of an app with several keys trimming to the same key
+	// are kept in key order for a deterministic evaluation.
+	for _, entries := range index {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].app != entries[j].app {
+				return entries[i].app < entries[j].app
+			}
+			return entries[i].key < entries[j].key
+		})
+	}
+	return index
+}
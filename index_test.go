@@ -0,0 +1,194 @@
+package wappalyzer
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// matchMapStringAll is the reference implementation of matchMapString
+// walking every app and every key of its fingerprint.
+func matchMapStringAll(f *CompiledFingerprints, keyValue map[string]string, part part) []matchPartResult {
+	var technologies []matchPartResult
+	for _, app := range sortedKeys(f.Apps) {
+		fingerprint := f.Apps[app]
+
+		patterns := make(map[string][]*ParsedPattern)
+		switch part {
+		case cookiesPart:
+			for key, pattern := range fingerprint.cookies {
+				patterns[key] = []*ParsedPattern{pattern}
+			}
+		case headersPart:
+			for key, pattern := range fingerprint.headers {
+				patterns[key] = []*ParsedPattern{pattern}
+			}
+		case metaPart:
+			patterns = fingerprint.meta
+		}
+
+		var matched bool
+		result := matchPartResult{application: app, confidence: 100}
+		for _, key := range sortedKeys(patterns) {
+			value, ok := keyValue[key]
+			if !ok {
+				continue
+			}
+			var keyMatched bool
+			for _, pattern := range patterns[key] {
+				if valid, version, _ := pattern.evaluate(value); valid {
+					keyMatched = true
+					if result.version == "" && version != "" {
+						result.version = version
+					}
+					result.confidence = pattern.Confidence
+					break
+				}
+			}
+			matched = matched || keyMatched
+			if keyMatched && part != metaPart {
+				break
+			}
+		}
+		if matched {
+			technologies = append(technologies, result)
+		}
+	}
+	return technologies
+}
+
+func TestKeyIndex(t *testing.T) {
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+	fingerprints := wappalyzer.GetCompiledFingerprints()
+
+	inputs := map[part][]map[string]string{
+		headersPart: {
+			{"server": "apache/2.4.41 (ubuntu)", "x-powered-by": "php/7.4.3", "x-shopid": "123"},
+			{"liferay-portal": "liferay community edition portal 7.2.0 ce ga1", "via": "1.1 varnish"},
+		},
+		cookiesPart: {
+			{"phpsessid": "abc", "_ga": "GA1.2", "laravel_session": "xyz"},
+		},
+		metaPart: {
+			{"generator": "WordPress 6.4.2"},
+			{"generator": "Joomla! - Open Source Content Management"},
+		},
+	}
+	for part, values := range inputs {
+		for _, value := range values {
+			expected := matchMapStringAll(fingerprints, value, part)
+			actual := fingerprints.matchMapString(value, part, true)
+			require.NotEmpty(t, actual, "could not match %s %v", part, value)
+			require.Equal(t, matchResultNames(expected), matchResultNames(actual), "indexed results differ for %s %v", part, value)
+
+			confidences := make(map[string]int, len(expected))
+			for _, result := range expected {
+				confidences[result.application] = result.confidence
+			}
+			for _, result := range actual {
+				require.Equal(t, confidences[result.application], result.confidence, "indexed confidence differs for %s", result.application)
+			}
+		}
+	}
+}
+
+func BenchmarkMatchMapString(b *testing.B) {
+	wappalyzer, err := New()
+	require.Nil(b, err, "could not create wappalyzer")
+	fingerprints := wappalyzer.GetCompiledFingerprints()
+
+	headers := map[string]string{
+		"server":        "nginx/1.18.0",
+		"content-type":  "text/html; charset=utf-8",
+		"x-powered-by":  "php/8.1.2",
+		"cache-control": "no-cache",
+		"set-cookie":    "phpsessid=abc; path=/",
+	}
+
+	b.Run("indexed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fingerprints.matchMapString(headers, headersPart, false)
+		}
+	})
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			matchMapStringAll(fingerprints, headers, headersPart)
+		}
+	})
+}
+
+func TestSelectorKeys(t *testing.T) {
+	tests := []struct {
+		selector string
+		expected []string
+	}{
+		{selector: "div", expected: []string{"div"}},
+		{selector: "IMG[src*='contentstack']", expected: []string{"img[src"}},
+		{selector: "footer.site-footer p > a", expected: []string{"a"}},
+		{selector: "div#main.container[data-id]", expected: []string{"#main"}},
+		{selector: "a.btn.primary:not(.hidden)", expected: []string{".btn"}},
+		{selector: "link[rel='stylesheet'][href*='a, b']", expected: []string{"link[rel"}},
+		{selector: "#app, .app, [data-app]", expected: []string{"#app", ".app", "[data-app"}},
+		{selector: "ul li:nth-child(2n+1)", expected: []string{"li"}},
+		{selector: "div ~ span + em", expected: []string{"em"}},
+		{selector: "[lang|='en']", expected: []string{"[lang"}},
+		{selector: "button[name='age_gate[confirm]']", expected: []string{"button[name"}},
+		{selector: `img[src*='/~/media/.+\.ashx']`, expected: []string{"img[src"}},
+		{selector: "*"},
+		{selector: ":root"},
+		{selector: "div, *"},
+		{selector: "[data-id!='1']"},
+		{selector: "[xlink|href]"},
+		{selector: `#a\:b`},
+		{selector: `[data\-id]`},
+		{selector: ""},
+	}
+	for _, test := range tests {
+		keys, ok := selectorKeys(test.selector)
+		require.Equal(t, test.expected != nil, ok, "could not get keys of %q", test.selector)
+		require.Equal(t, test.expected, keys, "could not get correct keys of %q", test.selector)
+	}
+}
+
+func TestDOMIndex(t *testing.T) {
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+	fingerprints := wappalyzer.GetCompiledFingerprints()
+
+	// Build a page with an element for every key of the index, so that
+	// as many selectors as possible have an element to match.
+	index := fingerprints.getDOMIndex()
+	builder := &strings.Builder{}
+	builder.WriteString("<html><body>")
+	for _, key := range sortedKeys(index.selectors) {
+		name := html.EscapeString(key[1:])
+		switch key[0] {
+		case '#':
+			fmt.Fprintf(builder, `<div id="%s"><span>Powered by %s</span></div>`, name, name)
+		case '.':
+			fmt.Fprintf(builder, `<a class="link %s" href="/%s">%s</a>`, name, name, name)
+		case '[':
+			fmt.Fprintf(builder, `<span %s="%s">%s</span>`, name, name, name)
+		default:
+			tag, attr, _ := strings.Cut(key, "[")
+			fmt.Fprintf(builder, `<%s %s>%s</%s>`, tag, attr, key, tag)
+		}
+	}
+	builder.WriteString("</body></html>")
+
+	elements, err := parseDocument(context.Background(), []byte(builder.String()))
+	require.Nil(t, err, "could not parse document")
+
+	expected, err := fingerprints.matchDOMIndex(context.Background(), elements, newDOMIndex(fingerprints.Apps, false), true)
+	require.Nil(t, err, "could not match dom")
+	require.NotEmpty(t, expected, "could not get dom matches")
+
+	results, err := fingerprints.matchDOM(context.Background(), elements, true)
+	require.Nil(t, err, "could not match dom")
+	require.Equal(t, matchResultNames(expected), matchResultNames(results), "indexed dom results differ")
+}
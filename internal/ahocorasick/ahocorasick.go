@@ -0,0 +1,246 @@
+// Package ahocorasick implements an Aho-Corasick automaton for finding
+// a set of literal patterns in an input in a single pass.
+//
+// Matching is case-insensitive for ASCII letters only: patterns and input
+// are both folded to lowercase byte by byte.
+package ahocorasick
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"sort"
+)
+
+// Matcher is a compiled Aho-Corasick automaton. It is safe for
+// concurrent use by multiple goroutines.
+type Matcher struct {
+	// root contains the dense transitions of the root node
+	root [256]int32
+	// edgeStart is the offset of the edges of each node in edgeBytes and
+	// edgeTargets. The edges of node n are in [edgeStart[n], edgeStart[n+1]).
+	edgeStart   []int32
+	edgeBytes   []byte
+	edgeTargets []int32
+	// fail is the failure link of each node
+	fail []int32
+	// outputs contains the patterns ending at each node
+	outputs [][]int32
+	// dict is the nearest node reachable through failure links that
+	// has outputs, or -1 if there is none.
+	dict []int32
+	// patterns is the number of patterns in the automaton
+	patterns int
+}
+
+// New builds a matcher for the patterns. Empty patterns never match.
+func New(patterns []string) *Matcher {
+	// Build the trie with sparse maps first
+	children := []map[byte]int32{{}}
+	outputs := [][]int32{nil}
+	for index, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		var node int32
+		for i := 0; i < len(pattern); i++ {
+			c := toLower(pattern[i])
+			next, ok := children[node][c]
+			if !ok {
+				next = int32(len(children))
+				children = append(children, map[byte]int32{})
+				outputs = append(outputs, nil)
+				children[node][c] = next
+			}
+			node = next
+		}
+		outputs[node] = append(outputs[node], int32(index))
+	}
+
+	m := &Matcher{
+		edgeStart: make([]int32, len(children)+1),
+		fail:      make([]int32, len(children)),
+		outputs:   outputs,
+		dict:      make([]int32, len(children)),
+		patterns:  len(patterns),
+	}
+	for node, edges := range children {
+		m.edgeStart[node] = int32(len(m.edgeBytes))
+
+		keys := make([]int, 0, len(edges))
+		for c := range edges {
+			keys = append(keys, int(c))
+		}
+		sort.Ints(keys)
+		for _, c := range keys {
+			m.edgeBytes = append(m.edgeBytes, byte(c))
+			m.edgeTargets = append(m.edgeTargets, edges[byte(c)])
+		}
+	}
+	m.edgeStart[len(children)] = int32(len(m.edgeBytes))
+
+	// Compute failure and dictionary links breadth first
+	queue := make([]int32, 0, len(children))
+	for c, next := range children[0] {
+		m.root[c] = next
+		queue = append(queue, next)
+	}
+	m.dict[0] = -1
+	for _, node := range queue {
+		m.dict[node] = -1
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for c, next := range children[node] {
+			fail := m.fail[node]
+			for {
+				if target, ok := m.transition(fail, c); ok {
+					m.fail[next] = target
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = m.fail[fail]
+			}
+
+			if target := m.fail[next]; len(m.outputs[target]) > 0 {
+				m.dict[next] = target
+			} else {
+				m.dict[next] = m.dict[target]
+			}
+			queue = append(queue, next)
+		}
+	}
+	return m
+}
+
+// FindAll returns the indexes of the patterns found in the input,
+// in increasing order. Each pattern is reported once.
+func (m *Matcher) FindAll(input string) []int {
+	if m.patterns == 0 {
+		return nil
+	}
+	seen := make([]uint64, (m.patterns+63)/64)
+
+	var found []int
+	report := func(node int32) {
+		for _, index := range m.outputs[node] {
+			if seen[index/64]&(1<<(index%64)) != 0 {
+				continue
+			}
+			seen[index/64] |= 1 << (index % 64)
+			found = append(found, int(index))
+		}
+	}
+
+	var node int32
+	for i := 0; i < len(input); i++ {
+		c := toLower(input[i])
+		for {
+			if next, ok := m.transition(node, c); ok {
+				node = next
+				break
+			}
+			if node == 0 {
+				break
+			}
+			node = m.fail[node]
+		}
+
+		for output := node; output > 0; output = m.dict[output] {
+			if len(m.outputs[output]) > 0 {
+				report(output)
+			}
+		}
+	}
+	sort.Ints(found)
+	return found
+}
+
+// transition returns the goto transition of a node for a byte
+func (m *Matcher) transition(node int32, c byte) (int32, bool) {
+	if node == 0 {
+		next := m.root[c]
+		return next, next != 0
+	}
+
+	edges := m.edgeBytes[m.edgeStart[node]:m.edgeStart[node+1]]
+	if len(edges) <= 8 {
+		for i, edge := range edges {
+			if edge == c {
+				return m.edgeTargets[int(m.edgeStart[node])+i], true
+			}
+		}
+		return 0, false
+	}
+	i := sort.Search(len(edges), func(i int) bool { return edges[i] >= c })
+	if i < len(edges) && edges[i] == c {
+		return m.edgeTargets[int(m.edgeStart[node])+i], true
+	}
+	return 0, false
+}
+
+// toLower folds an ASCII uppercase letter to lowercase
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// matcherData is the serialized form of a matcher
+type matcherData struct {
+	Root        [256]int32
+	EdgeStart   []int32
+	EdgeBytes   []byte
+	EdgeTargets []int32
+	Fail        []int32
+	Outputs     [][]int32
+	Dict        []int32
+	Patterns    int
+}
+
+// MarshalBinary encodes the compiled automaton, so that it can be
+// cached instead of being built again from the patterns.
+func (m *Matcher) MarshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(&matcherData{
+		Root:        m.root,
+		EdgeStart:   m.edgeStart,
+		EdgeBytes:   m.edgeBytes,
+		EdgeTargets: m.edgeTargets,
+		Fail:        m.fail,
+		Outputs:     m.outputs,
+		Dict:        m.dict,
+		Patterns:    m.patterns,
+	})
+	return buffer.Bytes(), err
+}
+
+// UnmarshalBinary decodes an automaton encoded with MarshalBinary
+func (m *Matcher) UnmarshalBinary(data []byte) error {
+	var decoded matcherData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&decoded); err != nil {
+		return err
+	}
+
+	nodes := len(decoded.Fail)
+	if len(decoded.EdgeStart) != nodes+1 || len(decoded.Outputs) != nodes || len(decoded.Dict) != nodes ||
+		len(decoded.EdgeBytes) != len(decoded.EdgeTargets) {
+		return errors.New("invalid aho-corasick automaton")
+	}
+	*m = Matcher{
+		root:        decoded.Root,
+		edgeStart:   decoded.EdgeStart,
+		edgeBytes:   decoded.EdgeBytes,
+		edgeTargets: decoded.EdgeTargets,
+		fail:        decoded.Fail,
+		outputs:     decoded.Outputs,
+		dict:        decoded.Dict,
+		patterns:    decoded.Patterns,
+	}
+	return nil
+}
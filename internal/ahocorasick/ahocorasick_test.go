@@ -0,0 +1,86 @@
+package ahocorasick
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		input    string
+		expected []int
+	}{
+		{name: "overlapping", patterns: []string{"abc", "bcd", "cde"}, input: "abcde", expected: []int{0, 1, 2}},
+		{name: "suffixes", patterns: []string{"he", "she", "his", "hers"}, input: "ushers", expected: []int{0, 1, 3}},
+		{name: "inner suffix", patterns: []string{"abcd", "bc", "c"}, input: "xabcy", expected: []int{1, 2}},
+		{name: "prefixes", patterns: []string{"jquery", "jquery.min", "jquery.min.js"}, input: "/js/jquery.min.js", expected: []int{0, 1, 2}},
+		{name: "repeated", patterns: []string{"ab", "ab", "b"}, input: "abab", expected: []int{0, 1, 2}},
+		{name: "case folding", patterns: []string{"JQuery", "min.js"}, input: "/JQUERY.MIN.JS", expected: []int{0, 1}},
+		{name: "no match", patterns: []string{"react", "vue"}, input: "angular", expected: nil},
+		{name: "empty input", patterns: []string{"a"}, input: "", expected: nil},
+		{name: "empty pattern", patterns: []string{"", "a"}, input: "a", expected: []int{1}},
+		{name: "no patterns", patterns: nil, input: "a", expected: nil},
+		{name: "non-ascii", patterns: []string{"café", "\xff\xfe", "naïve"}, input: "CAFé \x00\xff\xfe\x01", expected: []int{0, 1}},
+		{name: "non-ascii case", patterns: []string{"É"}, input: "é", expected: nil},
+		{name: "dense node", patterns: []string{"xa", "xb", "xc", "xd", "xe", "xf", "xg", "xh", "xi", "xj"}, input: "xj xb", expected: []int{1, 9}},
+	}
+	for _, test := range tests {
+		matcher := New(test.patterns)
+		require.Equal(t, test.expected, matcher.FindAll(test.input), "could not find correct patterns for %s", test.name)
+	}
+}
+
+func TestFindAllRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	alphabet := "abAB\xc3\xa9."
+	randomString := func(maxLength int) string {
+		builder := &strings.Builder{}
+		for i := random.Intn(maxLength + 1); i > 0; i-- {
+			builder.WriteByte(alphabet[random.Intn(len(alphabet))])
+		}
+		return builder.String()
+	}
+
+	for i := 0; i < 1000; i++ {
+		patterns := make([]string, random.Intn(20))
+		for j := range patterns {
+			patterns[j] = randomString(4)
+		}
+		input := randomString(40)
+
+		var expected []int
+		for j, pattern := range patterns {
+			if pattern != "" && strings.Contains(asciiLower(input), asciiLower(pattern)) {
+				expected = append(expected, j)
+			}
+		}
+		require.Equal(t, expected, New(patterns).FindAll(input), "could not find correct patterns %q in %q", patterns, input)
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	matcher := New([]string{"he", "she", "his", "hers"})
+	data, err := matcher.MarshalBinary()
+	require.Nil(t, err, "could not marshal matcher")
+
+	var decoded Matcher
+	err = decoded.UnmarshalBinary(data)
+	require.Nil(t, err, "could not unmarshal matcher")
+	require.Equal(t, matcher.FindAll("ushers"), decoded.FindAll("ushers"), "could not find patterns with unmarshaled matcher")
+
+	require.NotNil(t, decoded.UnmarshalBinary([]byte("invalid")), "could unmarshal invalid data")
+}
+
+// asciiLower folds the ASCII uppercase letters of a string to lowercase
+func asciiLower(value string) string {
+	folded := []byte(value)
+	for i, c := range folded {
+		folded[i] = toLower(c)
+	}
+	return string(folded)
+}
@@ -0,0 +1,220 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	wappalyzer "github.com/projectdiscovery/wappalyzergo"
+)
+
+// evalErrorName is the name of the error thrown when the page
+// Content-Security-Policy forbids eval.
+const evalErrorName = "EvalError"
+
+// batchRule is a browser rule bundled in a batch
+type batchRule struct {
+	app       string
+	detection *wappalyzer.DetectionRule
+	version   *wappalyzer.VersionExtraction
+	// expression is the JavaScript function evaluating the rule
+	expression string
+}
+
+// batchResult is the result of a rule of a batch, either
+// the value returned by the rule or the error it threw.
+type batchResult struct {
+	Value json.RawMessage `json:"v"`
+	Error string          `json:"e"`
+}
+
+// ruleBatch bundles the browser rules of all the apps in a single script,
+// evaluated in one round trip instead of one per rule.
+type ruleBatch struct {
+	apps  []string
+	rules []batchRule
+	// detection and version are the indexes of the rules of every app
+	detection map[string][]int
+	version   map[string][]int
+	// evaluate evaluates the script, evaluateInPage outside of tests
+	evaluate evaluateFunc
+}
+
+// newBatch creates an empty batch for the apps
+func newBatch(apps []string) *ruleBatch {
+	return &ruleBatch{
+		apps:      apps,
+		detection: make(map[string][]int),
+		version:   make(map[string][]int),
+		evaluate:  evaluateInPage,
+	}
+}
+
+// newRuleBatch bundles the browser rules of the fingerprints: the
+// detection rules of the apps not detected yet and the version rules of
+// the apps already detected. Rules of unknown types are ignored.
+func newRuleBatch(technologies map[string]string, fingerprints *wappalyzer.Fingerprints) *ruleBatch {
+	var apps []string
+	for app, fingerprint := range fingerprints.Apps {
+		if fingerprint.Browser != nil {
+			apps = append(apps, app)
+		}
+	}
+	sort.Strings(apps)
+
+	batch := newBatch(apps)
+	for _, app := range batch.apps {
+		browser := fingerprints.Apps[app].Browser
+		if _, alreadyDetected := technologies[app]; alreadyDetected {
+			batch.addVersionRules(app, browser)
+		} else {
+			batch.addDetectionRules(app, browser)
+		}
+	}
+	return batch
+}
+
+// newVersionBatch bundles the version rules of apps detected by a
+// previous batch.
+func newVersionBatch(apps []string, fingerprints *wappalyzer.Fingerprints) *ruleBatch {
+	batch := newBatch(apps)
+	for _, app := range apps {
+		if fingerprint, ok := fingerprints.Apps[app]; ok && fingerprint.Browser != nil {
+			batch.addVersionRules(app, fingerprint.Browser)
+		}
+	}
+	return batch
+}
+
+// addDetectionRules adds the detection rules of an app to the batch
+func (b *ruleBatch) addDetectionRules(app string, browser *wappalyzer.BrowserDetection) {
+	for i := range browser.Detection {
+		rule := &browser.Detection[i]
+		if expression := detectionExpression(rule); expression != "" {
+			b.detection[app] = append(b.detection[app], len(b.rules))
+			b.rules = append(b.rules, batchRule{app: app, detection: rule, expression: expression})
+		}
+	}
+}
+
+// addVersionRules adds the version rules of an app to the batch
+func (b *ruleBatch) addVersionRules(app string, browser *wappalyzer.BrowserDetection) {
+	for i := range browser.Version {
+		rule := &browser.Version[i]
+		if expression := versionExpression(rule); expression != "" {
+			b.version[app] = append(b.version[app], len(b.rules))
+			b.rules = append(b.rules, batchRule{app: app, version: rule, expression: expression})
+		}
+	}
+}
+
+// detectionExpression returns the function evaluating a detection rule,
+// returning true if the rule matches. The queries are evaluated from a
+// JSON string so that a syntax error only fails its own rule.
+func detectionExpression(rule *wappalyzer.DetectionRule) string {
+	switch rule.Type {
+	case "dom-selector":
+		return "() => !!document.querySelector(" + jsString(rule.Selector) + ")"
+	case "js-eval":
+		return "() => (0, eval)(" + jsString(rule.Query) + ") === true"
+	}
+	return ""
+}
+
+// versionExpression returns the function evaluating a version rule,
+// returning the version or an empty string.
+func versionExpression(rule *wappalyzer.VersionExtraction) string {
+	switch rule.Type {
+	case "dom-attribute":
+		return "() => { const el = document.querySelector(" + jsString(rule.Selector) + "); return el && el.getAttribute(" + jsString(rule.Attribute) + ") || ''; }"
+	case "js-eval":
+		return "() => { const value = (0, eval)(" + jsString(rule.Query) + "); return typeof value === 'string' ? value : ''; }"
+	}
+	return ""
+}
+
+// jsString returns a JavaScript string literal of a value
+func jsString(value string) string {
+	// JSON strings are valid JavaScript literals, with U+2028
+	// and U+2029 escaped by encoding/json.
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// script returns the script evaluating all the rules, each in its own
+// try/catch, and returning the array of their results.
+func (b *ruleBatch) script() string {
+	var builder strings.Builder
+	builder.WriteString("(() => {\n")
+	builder.WriteString("const results = [];\n")
+	builder.WriteString("const run = (rule) => { try { results.push({v: rule()}); } catch (e) { results.push({e: String(e && e.name || 'Error')}); } };\n")
+	for _, rule := range b.rules {
+		builder.WriteString("run(")
+		builder.WriteString(rule.expression)
+		builder.WriteString(");\n")
+	}
+	builder.WriteString("return results;\n")
+	builder.WriteString("})()")
+	return builder.String()
+}
+
+// run evaluates all the rules in a single round trip. The rules that could
+// not be evaluated because the page forbids eval are evaluated one by one.
+func (b *ruleBatch) run(ctx context.Context) ([]batchResult, error) {
+	if len(b.rules) == 0 {
+		return nil, nil
+	}
+
+	var results []batchResult
+	if err := b.evaluate(ctx, b.script(), &results); err != nil {
+		return nil, err
+	}
+	// Results are missing if the bundle itself failed
+	for len(results) < len(b.rules) {
+		results = append(results, batchResult{Error: "missing result"})
+	}
+
+	for i, result := range results[:len(b.rules)] {
+		if result.Error != evalErrorName {
+			continue
+		}
+		rule := b.rules[i]
+		if rule.detection != nil {
+			results[i] = newBatchResult(executeDetectionRule(ctx, b.evaluate, *rule.detection))
+		} else {
+			results[i] = newBatchResult(evaluateVersionRule(ctx, b.evaluate, *rule.version))
+		}
+	}
+	return results, nil
+}
+
+// newBatchResult creates the result of a rule evaluated on its own
+func newBatchResult(value interface{}) batchResult {
+	data, _ := json.Marshal(value)
+	return batchResult{Value: data}
+}
+
+// detected returns true if a detection rule of an app matched
+func (b *ruleBatch) detected(app string, results []batchResult) bool {
+	for _, index := range b.detection[app] {
+		var matched bool
+		if results[index].Error == "" && json.Unmarshal(results[index].Value, &matched) == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// extractedVersion returns the version of the first version rule
+// of an app returning one, applying the rule pattern if any.
+func (b *ruleBatch) extractedVersion(app string, results []batchResult) string {
+	for _, index := range b.version[app] {
+		var version string
+		if results[index].Error != "" || json.Unmarshal(results[index].Value, &version) != nil || version == "" {
+			continue
+		}
+		return applyVersionPattern(*b.rules[index].version, version)
+	}
+	return ""
+}
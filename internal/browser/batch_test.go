@@ -0,0 +1,135 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	wappalyzer "github.com/projectdiscovery/wappalyzergo"
+	"github.com/stretchr/testify/require"
+)
+
+// testFingerprints are apps with browser rules of every type
+var testFingerprints = &wappalyzer.Fingerprints{Apps: map[string]*wappalyzer.Fingerprint{
+	"Script": {Browser: &wappalyzer.BrowserDetection{
+		Detection: []wappalyzer.DetectionRule{{Type: "js-eval", Query: "window.Script !== undefined"}},
+		Version:   []wappalyzer.VersionExtraction{{Type: "js-eval", Query: "window.Script.version"}},
+	}},
+	"Widget": {Browser: &wappalyzer.BrowserDetection{
+		Detection: []wappalyzer.DetectionRule{{Type: "dom-selector", Selector: "div[data-widget]"}, {Type: "unknown"}},
+		Version:   []wappalyzer.VersionExtraction{{Type: "dom-attribute", Selector: "div[data-widget]", Attribute: "data-widget", Pattern: `v([\d.]+)`}},
+	}},
+	"Static": {},
+}}
+
+// fakePage evaluates the batch script and the rules evaluated on their
+// own with the results of the expressions containing a key.
+func fakePage(batch []batchResult, values map[string]interface{}) evaluateFunc {
+	return func(_ context.Context, expression string, result interface{}) error {
+		var value interface{} = batch
+		if !strings.HasPrefix(expression, "(() => {\nconst results") {
+			value = nil
+			for key, keyValue := range values {
+				if strings.Contains(expression, key) {
+					value = keyValue
+				}
+			}
+			if value == nil {
+				return errors.New("unexpected expression")
+			}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, result)
+	}
+}
+
+func TestRuleBatch(t *testing.T) {
+	t.Run("rules", func(t *testing.T) {
+		batch := newRuleBatch(map[string]string{"Widget": ""}, testFingerprints)
+		require.Equal(t, []string{"Script", "Widget"}, batch.apps, "could not get apps with browser rules")
+		require.Len(t, batch.rules, 2, "could not bundle rules")
+		require.Equal(t, map[string][]int{"Script": {0}}, batch.detection, "could not bundle only detection rules of undetected apps")
+		require.Equal(t, map[string][]int{"Widget": {1}}, batch.version, "could not bundle only version rules of detected apps")
+
+		versions := newVersionBatch([]string{"Script"}, testFingerprints)
+		require.Equal(t, map[string][]int{"Script": {0}}, versions.version, "could not bundle version rules of detected apps")
+		require.Empty(t, versions.detection, "could bundle detection rules in version batch")
+	})
+
+	t.Run("script", func(t *testing.T) {
+		batch := newRuleBatch(nil, testFingerprints)
+		script := batch.script()
+		require.True(t, strings.HasPrefix(script, "(() => {\n"), "could not wrap script in a function")
+		require.True(t, strings.HasSuffix(script, "return results;\n})()"), "could not return results")
+		require.Equal(t, len(batch.rules), strings.Count(script, "\nrun("), "could not run every rule")
+		require.Contains(t, script, `run(() => (0, eval)("window.Script !== undefined") === true);`, "could not isolate js-eval query")
+		require.Contains(t, script, `run(() => !!document.querySelector("div[data-widget]"));`, "could not bundle dom-selector rule")
+	})
+
+	t.Run("isolation", func(t *testing.T) {
+		node, err := exec.LookPath("node")
+		if err != nil {
+			t.Skip("node is not installed")
+		}
+
+		batch := newBatch([]string{"App"})
+		batch.addDetectionRules("App", &wappalyzer.BrowserDetection{Detection: []wappalyzer.DetectionRule{
+			{Type: "js-eval", Query: "missing.property"},
+			{Type: "js-eval", Query: "("},
+			{Type: "js-eval", Query: "throw new EvalError('csp')"},
+			{Type: "js-eval", Query: "1 + 1 === 2"},
+		}})
+		output, err := exec.Command(node, "-e", "console.log(JSON.stringify("+batch.script()+"))").Output()
+		require.Nil(t, err, "could not evaluate script")
+
+		var results []batchResult
+		require.Nil(t, json.Unmarshal(output, &results), "could not decode results")
+		require.Len(t, results, 4, "could not get a result per rule")
+		require.Equal(t, "ReferenceError", results[0].Error, "could not isolate throwing rule")
+		require.Equal(t, "SyntaxError", results[1].Error, "could not isolate invalid rule")
+		require.Equal(t, evalErrorName, results[2].Error, "could not get eval error")
+		require.Equal(t, "true", string(results[3].Value), "could not evaluate rule after failing ones")
+	})
+
+	t.Run("run", func(t *testing.T) {
+		batch := newRuleBatch(nil, testFingerprints)
+		batch.evaluate = fakePage([]batchResult{
+			{Error: evalErrorName},
+			{Value: json.RawMessage(`true`)},
+		}, map[string]interface{}{"window.Script !== undefined": true})
+
+		results, err := batch.run(context.Background())
+		require.Nil(t, err, "could not run batch")
+		require.Equal(t, "true", string(results[0].Value), "could not evaluate eval error rule on its own")
+		require.True(t, batch.detected("Script", results), "could not detect app from fallback")
+		require.True(t, batch.detected("Widget", results), "could not detect app from dom-selector")
+
+		versions := newVersionBatch([]string{"Script", "Widget"}, testFingerprints)
+		versions.evaluate = fakePage([]batchResult{
+			{Value: json.RawMessage(`"1.2.3"`)},
+			{Value: json.RawMessage(`"v4.5"`)},
+		}, nil)
+		results, err = versions.run(context.Background())
+		require.Nil(t, err, "could not run version batch")
+		require.Equal(t, "1.2.3", versions.extractedVersion("Script", results), "could not extract js-eval version")
+		require.Equal(t, "4.5", versions.extractedVersion("Widget", results), "could not apply version pattern")
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		batch := newRuleBatch(nil, testFingerprints)
+		batch.evaluate = fakePage([]batchResult{{Value: json.RawMessage(`false`)}}, nil)
+
+		results, err := batch.run(context.Background())
+		require.Nil(t, err, "could not run batch")
+		require.Len(t, results, len(batch.rules), "could not fill missing results")
+		require.False(t, batch.detected("Script", results), "could detect app from false result")
+		require.False(t, batch.detected("Widget", results), "could detect app from missing result")
+		require.Empty(t, batch.extractedVersion("Script", results), "could extract version from missing result")
+	})
+}
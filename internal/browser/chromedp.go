@@ -6,18 +6,22 @@ import (
 	"github.com/chromedp/chromedp"
 )
 
-// SetupContext creates a chromedp context with the specified options.
-// Returns the context and a cancel function that should be called when done.
-func SetupContext(ctx context.Context, headless bool, userAgent string) (context.Context, context.CancelFunc) {
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+// execAllocatorOptions returns the options used to start Chrome
+func execAllocatorOptions(headless bool, userAgent string) []chromedp.ExecAllocatorOption {
+	return append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.UserAgent(userAgent),
 		chromedp.Flag("headless", headless),
 		chromedp.Flag("disable-gpu", true),
 		chromedp.Flag("no-sandbox", true),
 		chromedp.Flag("disable-dev-shm-usage", true),
 	)
+}
 
-	allocCtx, cancel1 := chromedp.NewExecAllocator(ctx, opts...)
+// SetupContext creates a chromedp context with the specified options.
+// Returns the context and a cancel function that should be called when done.
+// Each context starts its own Chrome process, use a Pool to share one.
+func SetupContext(ctx context.Context, headless bool, userAgent string) (context.Context, context.CancelFunc) {
+	allocCtx, cancel1 := chromedp.NewExecAllocator(ctx, execAllocatorOptions(headless, userAgent)...)
 	browserCtx, cancel2 := chromedp.NewContext(allocCtx)
 
 	// Return combined cancel function
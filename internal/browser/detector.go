@@ -2,40 +2,70 @@ package browser
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/chromedp/chromedp"
 	wappalyzer "github.com/projectdiscovery/wappalyzergo"
 )
 
+// errBrowserCrashed is returned when Chrome crashed while loading a page
+var errBrowserCrashed = errors.New("browser crashed")
+
 // Detector handles browser-based technology detection and version extraction.
+// Pages are loaded in tabs of a shared browser pool.
 type Detector struct {
-	headless  bool
-	userAgent string
-	waitTime  time.Duration
+	pool     *Pool
+	waitTime time.Duration
 }
 
-// NewDetector creates a new browser detector with the specified configuration.
-func NewDetector(headless bool, userAgent string, waitTime time.Duration) *Detector {
+// NewDetector creates a new browser detector loading pages in the tabs of a pool.
+func NewDetector(pool *Pool, waitTime time.Duration) *Detector {
 	return &Detector{
-		headless:  headless,
-		userAgent: userAgent,
-		waitTime:  waitTime,
+		pool:     pool,
+		waitTime: waitTime,
 	}
 }
 
+// EnhanceWithVersions loads a page in a browser tab and runs the browser
+// rules of the fingerprints, adding the detected technologies and versions.
+// The page is loaded again in a new Chrome process if Chrome crashed.
 func (d *Detector) EnhanceWithVersions(
 	ctx context.Context,
 	url string,
 	technologies map[string]string,
 	fingerprints *wappalyzer.Fingerprints,
 ) error {
-	// Setup browser context
-	browserCtx, cancel := SetupContext(ctx, d.headless, d.userAgent)
+	err := d.enhanceWithVersions(ctx, url, technologies, fingerprints)
+	if errors.Is(err, errBrowserCrashed) && ctx.Err() == nil {
+		err = d.enhanceWithVersions(ctx, url, technologies, fingerprints)
+	}
+	return err
+}
+
+func (d *Detector) enhanceWithVersions(
+	ctx context.Context,
+	url string,
+	technologies map[string]string,
+	fingerprints *wappalyzer.Fingerprints,
+) (err error) {
+	tab, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil && tab.Crashed() {
+			err = fmt.Errorf("%w: %v", errBrowserCrashed, err)
+		}
+		tab.Release(err != nil)
+	}()
+
+	browserCtx, cancel := tab.Context(ctx)
 	defer cancel()
 
 	// Navigate and wait for page to be ready
-	err := chromedp.Run(browserCtx,
+	err = chromedp.Run(browserCtx,
 		chromedp.Navigate(url),
 		chromedp.WaitReady("body"),
 		chromedp.Sleep(d.waitTime),
@@ -45,40 +75,39 @@ func (d *Detector) EnhanceWithVersions(
 		return err
 	}
 
-	// Execute detection and version extraction for each app individually
-	// This avoids JavaScript syntax errors from special characters in variable names
-	for appName, fingerprint := range fingerprints.Apps {
-		// Skip if no browser detection configured
+	// Evaluate the rules of all the apps in a single round trip. Each rule
+	// is isolated in the batch, so that a failing query does not affect the others
+	batch := newRuleBatch(technologies, fingerprints)
+	results, err := batch.run(browserCtx)
+	if err != nil {
+		return err
+	}
 
-		if fingerprint.Browser == nil {
+	var detected []string
+	for _, appName := range batch.apps {
+		// Only the version rules of the apps already detected were evaluated
+		if _, alreadyDetected := technologies[appName]; alreadyDetected {
+			if version := batch.extractedVersion(appName, results); version != "" {
+				technologies[appName] = version
+			}
 			continue
 		}
-
-		// Check if already detected by static analysis
-		_, alreadyDetected := technologies[appName]
-
-		// Run detection if not already detected
-		detected := alreadyDetected
-		if !detected && len(fingerprint.Browser.Detection) > 0 {
-			detected = ExecuteDetectionRules(browserCtx, fingerprint.Browser.Detection)
+		if batch.detected(appName, results) {
+			technologies[appName] = ""
+			detected = append(detected, appName)
 		}
+	}
 
-		// If detected (either already or via browser), try to extract version
-		if detected {
-			version := ""
-
-			// If version extraction rules exist, try them
-			if len(fingerprint.Browser.Version) > 0 {
-				version = ExtractVersion(browserCtx, fingerprint.Browser.Version)
-			}
-
-			// Update or add technology
-			if version != "" {
-				technologies[appName] = version
-			} else if !alreadyDetected {
-				// Add without version if detected but no version found
-				technologies[appName] = ""
-			}
+	// Extract the versions of the apps detected by the browser
+	// in a second round trip, only if any was detected
+	versions := newVersionBatch(detected, fingerprints)
+	results, err = versions.run(browserCtx)
+	if err != nil {
+		return err
+	}
+	for _, appName := range detected {
+		if version := versions.extractedVersion(appName, results); version != "" {
+			technologies[appName] = version
 		}
 	}
 
@@ -0,0 +1,290 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/chromedp/chromedp"
+)
+
+const (
+	// defaultMaxTabs is the default number of tabs open at the same time
+	defaultMaxTabs = 1
+	// defaultMaxPagesPerTab is the default number of pages loaded in a tab before it is recycled
+	defaultMaxPagesPerTab = 20
+)
+
+// ErrPoolClosed is returned when acquiring a tab from a closed pool
+var ErrPoolClosed = errors.New("browser pool is closed")
+
+// PoolOptions configures a browser pool.
+type PoolOptions struct {
+	Headless  bool
+	UserAgent string
+	// MaxTabs is the maximum number of tabs open at the same time (default 1)
+	MaxTabs int
+	// MaxPagesPerTab is the number of pages loaded in a tab before
+	// it is closed and replaced by a new one (default 20)
+	MaxPagesPerTab int
+}
+
+// Pool shares a single Chrome process between the scans, handing out
+// a bounded number of tabs. Chrome is started on the first use and
+// restarted if it crashes.
+type Pool struct {
+	options PoolOptions
+	// slots bounds the number of tabs in use
+	slots chan struct{}
+	// startBrowser and openTab start Chrome and open tabs,
+	// replaced in tests to run without Chrome.
+	startBrowser func(ctx context.Context, options PoolOptions) (*browserInstance, error)
+	openTab      func(ctx context.Context, browser *browserInstance) (context.Context, context.CancelFunc, error)
+
+	mutex   sync.Mutex
+	browser *browserInstance
+	// starting is closed once the Chrome process being started is
+	// ready or failed to start, nil if none is being started.
+	starting chan struct{}
+	idle     []*Tab
+	closed   bool
+}
+
+// browserInstance is a running Chrome process
+type browserInstance struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// crashed returns true if the connection to Chrome was lost
+func (b *browserInstance) crashed() bool {
+	return b.ctx.Err() != nil
+}
+
+// Tab is a browser tab acquired from a pool. It must be released
+// with Release once the page is processed.
+type Tab struct {
+	pool    *Pool
+	browser *browserInstance
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pages   int
+}
+
+// NewPool creates a new browser pool. Chrome is started lazily.
+func NewPool(options PoolOptions) *Pool {
+	if options.MaxTabs <= 0 {
+		options.MaxTabs = defaultMaxTabs
+	}
+	if options.MaxPagesPerTab <= 0 {
+		options.MaxPagesPerTab = defaultMaxPagesPerTab
+	}
+	return &Pool{
+		options:      options,
+		slots:        make(chan struct{}, options.MaxTabs),
+		startBrowser: startChrome,
+		openTab:      openChromeTab,
+	}
+}
+
+// Acquire returns a tab, waiting for one to be released if all the tabs
+// are in use. Idle tabs are reused, and Chrome is restarted if it crashed.
+func (p *Pool) Acquire(ctx context.Context) (*Tab, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	tab, err := p.acquire(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	tab.pages++
+	return tab, nil
+}
+
+// acquire returns an idle tab of the running Chrome process or opens a
+// new one. Chrome is started and tabs are opened without holding the lock.
+func (p *Pool) acquire(ctx context.Context) (*Tab, error) {
+	browser, err := p.currentBrowser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tab := p.idleTab(browser); tab != nil {
+		return tab, nil
+	}
+
+	tabCtx, tabCancel, err := p.openTab(ctx, browser)
+	if err != nil {
+		return nil, err
+	}
+	return &Tab{pool: p, browser: browser, ctx: tabCtx, cancel: tabCancel}, nil
+}
+
+// idleTab returns an idle tab of the browser, closing the idle
+// tabs that were closed or belong to a previous Chrome process.
+func (p *Pool) idleTab(browser *browserInstance) *Tab {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for len(p.idle) > 0 {
+		tab := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if tab.browser == browser && tab.ctx.Err() == nil {
+			return tab
+		}
+		tab.cancel()
+	}
+	return nil
+}
+
+// currentBrowser returns the running Chrome process, starting a new
+// one if none was started yet or if it crashed. A single process is
+// started at a time, the other callers wait for it or for their context.
+func (p *Pool) currentBrowser(ctx context.Context) (*browserInstance, error) {
+	for {
+		p.mutex.Lock()
+		if p.closed {
+			p.mutex.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if p.browser != nil && !p.browser.crashed() {
+			browser := p.browser
+			p.mutex.Unlock()
+			return browser, nil
+		}
+		if starting := p.starting; starting != nil {
+			p.mutex.Unlock()
+			select {
+			case <-starting:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		if p.browser != nil {
+			p.browser.cancel()
+			p.browser = nil
+		}
+		starting := make(chan struct{})
+		p.starting = starting
+		p.mutex.Unlock()
+
+		browser, err := p.startBrowser(ctx, p.options)
+
+		p.mutex.Lock()
+		p.starting = nil
+		close(starting)
+		if err == nil && p.closed {
+			browser.cancel()
+			err = ErrPoolClosed
+		}
+		if err == nil {
+			p.browser = browser
+		}
+		p.mutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return browser, nil
+	}
+}
+
+// startChrome starts a Chrome process. The process outlives the context,
+// which only bounds the start.
+func startChrome(ctx context.Context, options PoolOptions) (*browserInstance, error) {
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), execAllocatorOptions(options.Headless, options.UserAgent)...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		browserCancel()
+		allocCancel()
+	}
+
+	// Start Chrome now so that the tabs share the process
+	stop := context.AfterFunc(ctx, cancel)
+	err := chromedp.Run(browserCtx)
+	if !stop() {
+		cancel()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &browserInstance{ctx: browserCtx, cancel: cancel}, nil
+}
+
+// openChromeTab opens a new tab in a running Chrome process. The tab
+// outlives the context, which only bounds the opening.
+func openChromeTab(ctx context.Context, browser *browserInstance) (context.Context, context.CancelFunc, error) {
+	tabCtx, tabCancel := chromedp.NewContext(browser.ctx)
+	stop := context.AfterFunc(ctx, tabCancel)
+	err := chromedp.Run(tabCtx)
+	if !stop() {
+		tabCancel()
+		return nil, nil, ctx.Err()
+	}
+	if err != nil {
+		tabCancel()
+		return nil, nil, err
+	}
+	return tabCtx, tabCancel, nil
+}
+
+// Context returns the chromedp context of the tab, bound to the lifetime
+// of a parent context such as a scan deadline. The returned cancel
+// function must be called once done with the context.
+func (t *Tab) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if deadline, ok := parent.Deadline(); ok {
+		ctx, cancel = context.WithDeadline(t.ctx, deadline)
+	} else {
+		ctx, cancel = context.WithCancel(t.ctx)
+	}
+	stop := context.AfterFunc(parent, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// Crashed returns true if Chrome crashed while the tab was in use
+func (t *Tab) Crashed() bool {
+	return t.browser.crashed()
+}
+
+// Release returns the tab to the pool. Tabs that failed, loaded too many
+// pages or belong to a crashed Chrome process are closed instead of reused.
+func (t *Tab) Release(failed bool) {
+	p := t.pool
+	defer func() { <-p.slots }()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if failed || p.closed || t.pages >= p.options.MaxPagesPerTab || t.ctx.Err() != nil || t.browser != p.browser {
+		t.cancel()
+		return
+	}
+	p.idle = append(p.idle, t)
+}
+
+// Close closes all the tabs and stops Chrome
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+	for _, tab := range p.idle {
+		tab.cancel()
+	}
+	p.idle = nil
+	if p.browser != nil {
+		p.browser.cancel()
+		p.browser = nil
+	}
+}
@@ -0,0 +1,171 @@
+package browser
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLauncher starts fake Chrome processes and tabs, so that the
+// pool can be tested without Chrome.
+type fakeLauncher struct {
+	starts atomic.Int32
+	tabs   atomic.Int32
+	// started is closed to let the starts complete, nil to start at once
+	started chan struct{}
+}
+
+// newTestPool creates a pool using the fake launcher
+func newTestPool(launcher *fakeLauncher, options PoolOptions) *Pool {
+	pool := NewPool(options)
+	pool.startBrowser = launcher.startBrowser
+	pool.openTab = launcher.openTab
+	return pool
+}
+
+func (l *fakeLauncher) startBrowser(ctx context.Context, _ PoolOptions) (*browserInstance, error) {
+	if l.started != nil {
+		select {
+		case <-l.started:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	l.starts.Add(1)
+	browserCtx, cancel := context.WithCancel(context.Background())
+	return &browserInstance{ctx: browserCtx, cancel: cancel}, nil
+}
+
+func (l *fakeLauncher) openTab(_ context.Context, browser *browserInstance) (context.Context, context.CancelFunc, error) {
+	l.tabs.Add(1)
+	tabCtx, cancel := context.WithCancel(browser.ctx)
+	return tabCtx, cancel, nil
+}
+
+func TestPool(t *testing.T) {
+	t.Run("slots", func(t *testing.T) {
+		pool := newTestPool(&fakeLauncher{}, PoolOptions{MaxTabs: 2})
+		defer pool.Close()
+
+		first, err := pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire tab")
+		_, err = pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire second tab")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = pool.Acquire(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded, "could acquire more tabs than slots")
+
+		first.Release(false)
+		_, err = pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire released slot")
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		launcher := &fakeLauncher{}
+		pool := newTestPool(launcher, PoolOptions{})
+		defer pool.Close()
+
+		tab, err := pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire tab")
+		tab.Release(false)
+
+		reused, err := pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire idle tab")
+		require.Same(t, tab, reused, "could not reuse idle tab")
+		require.Equal(t, int32(1), launcher.tabs.Load(), "could open another tab")
+
+		reused.Release(true)
+		require.NotNil(t, tab.ctx.Err(), "could not close failed tab")
+	})
+
+	t.Run("recycle", func(t *testing.T) {
+		launcher := &fakeLauncher{}
+		pool := newTestPool(launcher, PoolOptions{MaxPagesPerTab: 2})
+		defer pool.Close()
+
+		var tabs []*Tab
+		for i := 0; i < 3; i++ {
+			tab, err := pool.Acquire(context.Background())
+			require.Nil(t, err, "could not acquire tab")
+			tabs = append(tabs, tab)
+			tab.Release(false)
+		}
+		require.Same(t, tabs[0], tabs[1], "could not reuse tab before max pages")
+		require.NotSame(t, tabs[1], tabs[2], "could reuse tab after max pages")
+		require.NotNil(t, tabs[0].ctx.Err(), "could not close recycled tab")
+		require.Equal(t, int32(2), launcher.tabs.Load(), "could not open new tab")
+		require.Equal(t, int32(1), launcher.starts.Load(), "could start another browser")
+	})
+
+	t.Run("crash", func(t *testing.T) {
+		launcher := &fakeLauncher{}
+		pool := newTestPool(launcher, PoolOptions{})
+		defer pool.Close()
+
+		tab, err := pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire tab")
+		tab.browser.cancel()
+		require.True(t, tab.Crashed(), "could not detect crash")
+		tab.Release(false)
+
+		restarted, err := pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire tab after crash")
+		require.NotSame(t, tab, restarted, "could reuse tab of crashed browser")
+		require.False(t, restarted.Crashed(), "could get tab of crashed browser")
+		require.Equal(t, int32(2), launcher.starts.Load(), "could not restart browser")
+	})
+
+	t.Run("start", func(t *testing.T) {
+		launcher := &fakeLauncher{started: make(chan struct{})}
+		pool := newTestPool(launcher, PoolOptions{MaxTabs: 3})
+		defer pool.Close()
+
+		// The start is bounded by the context of the caller
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := pool.Acquire(ctx)
+		require.ErrorIs(t, err, context.DeadlineExceeded, "could not stop waiting for start")
+
+		// Concurrent callers share a single start
+		var wg sync.WaitGroup
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				tab, err := pool.Acquire(context.Background())
+				if err == nil {
+					tab.Release(false)
+				}
+				errs <- err
+			}()
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(launcher.started)
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.Nil(t, err, "could not acquire tab")
+		}
+		require.Equal(t, int32(1), launcher.starts.Load(), "could start browser more than once")
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		pool := newTestPool(&fakeLauncher{}, PoolOptions{})
+		tab, err := pool.Acquire(context.Background())
+		require.Nil(t, err, "could not acquire tab")
+
+		pool.Close()
+		tab.Release(false)
+		require.NotNil(t, tab.ctx.Err(), "could not close tab released after close")
+
+		_, err = pool.Acquire(context.Background())
+		require.ErrorIs(t, err, ErrPoolClosed, "could acquire tab from closed pool")
+	})
+}
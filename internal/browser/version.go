@@ -2,94 +2,111 @@ package browser
 
 import (
 	"context"
-	"fmt"
 	"regexp"
 
 	"github.com/chromedp/chromedp"
 	wappalyzer "github.com/projectdiscovery/wappalyzergo"
 )
 
+// evaluateFunc evaluates a JavaScript expression in a page, decoding its value into result
+type evaluateFunc func(ctx context.Context, expression string, result interface{}) error
+
+// evaluateInPage evaluates an expression in the page of a chromedp context
+func evaluateInPage(ctx context.Context, expression string, result interface{}) error {
+	return chromedp.Run(ctx, chromedp.Evaluate(expression, result))
+}
+
 // ExecuteDetectionRules runs browser detection rules and returns true if ANY rule matches.
 // Rules can be DOM selectors or JavaScript eval expressions.
+// Each rule is a round trip to the browser, Detector batches them instead.
 func ExecuteDetectionRules(ctx context.Context, rules []wappalyzer.DetectionRule) bool {
 	for _, rule := range rules {
-		var result bool
-
-		switch rule.Type {
-		case "dom-selector":
-			// Check if DOM element exists
-			err := chromedp.Run(ctx,
-				chromedp.Evaluate(fmt.Sprintf(`!!document.querySelector('%s')`, rule.Selector), &result),
-			)
-			if err == nil && result {
-				return true
-			}
-
-		case "js-eval":
-			// Execute JavaScript and check for truthy result
-			err := chromedp.Run(ctx,
-				chromedp.Evaluate(rule.Query, &result),
-			)
-			if err == nil && result {
-				return true
-			}
+		if executeDetectionRule(ctx, evaluateInPage, rule) {
+			return true
 		}
 	}
 
 	return false
 }
 
+// executeDetectionRule runs a single detection rule
+func executeDetectionRule(ctx context.Context, evaluate evaluateFunc, rule wappalyzer.DetectionRule) bool {
+	var result bool
+
+	switch rule.Type {
+	case "dom-selector":
+		// Check if DOM element exists
+		err := evaluate(ctx, `!!document.querySelector(`+jsString(rule.Selector)+`)`, &result)
+		return err == nil && result
+
+	case "js-eval":
+		// Execute JavaScript and check for truthy result
+		err := evaluate(ctx, rule.Query, &result)
+		return err == nil && result
+	}
+
+	return false
+}
+
 // ExtractVersion tries version extraction rules in order and returns the first successful result.
 // Supports both DOM attribute extraction and JavaScript evaluation.
 func ExtractVersion(ctx context.Context, rules []wappalyzer.VersionExtraction) string {
 	for _, rule := range rules {
-		var version string
-
-		switch rule.Type {
-		case "dom-attribute":
-			// Get attribute value from DOM element
-			query := fmt.Sprintf(`
-				(() => {
-					const el = document.querySelector('%s');
-					if (el) {
-						const value = el.getAttribute('%s');
-						if (value) {
-							return value;
-						}
-					}
-					return '';
-				})()
-			`, rule.Selector, rule.Attribute)
-
-			err := chromedp.Run(ctx,
-				chromedp.Evaluate(query, &version),
-			)
-
-			if err == nil && version != "" {
-				// Apply pattern extraction if specified
-				if rule.Pattern != "" {
-					re, err := regexp.Compile(rule.Pattern)
-					if err == nil {
-						matches := re.FindStringSubmatch(version)
-						if len(matches) > 1 {
-							return matches[1] // Return first capture group
-						}
+		if version := evaluateVersionRule(ctx, evaluateInPage, rule); version != "" {
+			return applyVersionPattern(rule, version)
+		}
+	}
+
+	return ""
+}
+
+// evaluateVersionRule runs a single version rule, returning the raw version
+func evaluateVersionRule(ctx context.Context, evaluate evaluateFunc, rule wappalyzer.VersionExtraction) string {
+	var version string
+
+	switch rule.Type {
+	case "dom-attribute":
+		// Get attribute value from DOM element
+		query := `
+			(() => {
+				const el = document.querySelector(` + jsString(rule.Selector) + `);
+				if (el) {
+					const value = el.getAttribute(` + jsString(rule.Attribute) + `);
+					if (value) {
+						return value;
 					}
 				}
-				return version
-			}
-
-		case "js-eval":
-			// Execute JavaScript to get version
-			err := chromedp.Run(ctx,
-				chromedp.Evaluate(rule.Query, &version),
-			)
-
-			if err == nil && version != "" {
-				return version
-			}
+				return '';
+			})()
+		`
+
+		if err := evaluate(ctx, query, &version); err != nil {
+			return ""
+		}
+
+	case "js-eval":
+		// Execute JavaScript to get version
+		if err := evaluate(ctx, rule.Query, &version); err != nil {
+			return ""
 		}
 	}
 
-	return ""
+	return version
+}
+
+// applyVersionPattern extracts the version from the value of a dom-attribute
+// rule with the first capture group of its pattern, if any.
+func applyVersionPattern(rule wappalyzer.VersionExtraction, version string) string {
+	if rule.Type != "dom-attribute" || rule.Pattern == "" {
+		return version
+	}
+
+	re, err := regexp.Compile(rule.Pattern)
+	if err == nil {
+		matches := re.FindStringSubmatch(version)
+		if len(matches) > 1 {
+			return matches[1] // Return first capture group
+		}
+	}
+	return version
 }
@@ -0,0 +1,441 @@
+package wappalyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// MergeStrategy defines how an app loaded from a source is merged with
+// an app of the same name loaded from an earlier source.
+type MergeStrategy int
+
+const (
+	// MergeReplace replaces the existing app with the new one
+	MergeReplace MergeStrategy = iota
+	// MergeSkip keeps the existing app and ignores the new one
+	MergeSkip
+	// MergeDeep merges the new app into the existing one field by field.
+	// Maps are merged key by key with new values winning, lists are
+	// appended without duplicates and non-empty scalar fields replace
+	// the existing ones.
+	MergeDeep
+)
+
+// String returns the name of the merge strategy
+func (m MergeStrategy) String() string {
+	switch m {
+	case MergeReplace:
+		return "replace"
+	case MergeSkip:
+		return "skip"
+	case MergeDeep:
+		return "deep"
+	}
+	return "unknown"
+}
+
+// Source is a source of fingerprints for a Loader
+type Source struct {
+	// Name identifies the source in the load report
+	Name string
+	// Strategy is the merge strategy for the apps already
+	// loaded from earlier sources. Defaults to MergeReplace.
+	Strategy MergeStrategy
+
+	load func() (map[string]*Fingerprint, error)
+	// hash returns the content hash of the source for the
+	// fingerprints cache. It is nil if the source cannot be cached.
+	hash func() ([]byte, error)
+}
+
+// WithStrategy returns a copy of the source using the merge strategy
+func (s Source) WithStrategy(strategy MergeStrategy) Source {
+	s.Strategy = strategy
+	return s
+}
+
+// EmbeddedSource returns a source for the fingerprints embedded in the library.
+// The embedded fingerprints are decoded on the first load, and every load
+// returns its own copy of them.
+func EmbeddedSource() Source {
+	return Source{
+		Name: "embedded",
+		load: func() (map[string]*Fingerprint, error) {
+			apps, err := loadEmbeddedFingerprints()
+			if err != nil {
+				return nil, err
+			}
+			return cloneApps(apps), nil
+		},
+		hash: hashEmbeddedFingerprints,
+	}
+}
+
+// FileSource returns a source for a fingerprints JSON file
+func FileSource(filePath string) Source {
+	return Source{
+		Name: filePath,
+		load: func() (map[string]*Fingerprint, error) {
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				return nil, err
+			}
+			apps, err := parseFingerprints(data, filePath)
+			if err != nil {
+				return nil, err
+			}
+			if len(apps) == 0 {
+				return nil, fmt.Errorf("no fingerprints found in file: %s", filePath)
+			}
+			return apps, nil
+		},
+		hash: func() ([]byte, error) {
+			return hashFile(filePath)
+		},
+	}
+}
+
+// DirectorySource returns a source for all the fingerprints JSON files
+// of a directory, using the per-category layout of data/fingerprints.
+// See FSSource for the loading order.
+func DirectorySource(dirPath string) Source {
+	return FSSource(dirPath, os.DirFS(dirPath))
+}
+
+// FSSource returns a source for all the fingerprints JSON files of a
+// file system, using the per-category layout of data/fingerprints.
+//
+// Files are walked recursively in lexical order, later files
+// replacing the apps of earlier ones.
+func FSSource(name string, fsys fs.FS) Source {
+	return Source{
+		Name: name,
+		load: func() (map[string]*Fingerprint, error) {
+			apps := make(map[string]*Fingerprint)
+			var files int
+			err := walkFingerprintFiles(fsys, func(path string, data []byte) error {
+				files++
+				fileApps, err := parseFingerprints(data, path)
+				if err != nil {
+					return err
+				}
+				maps.Copy(apps, fileApps)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			if files == 0 {
+				return nil, fmt.Errorf("no fingerprint files found in %s", name)
+			}
+			return apps, nil
+		},
+		hash: func() ([]byte, error) {
+			return hashFS(fsys)
+		},
+	}
+}
+
+// walkFingerprintFiles calls fn with the contents of every JSON file of
+// the file system, recursively and in lexical order.
+func walkFingerprintFiles(fsys fs.FS, fn func(path string, data []byte) error) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip directories and non-JSON files
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, data)
+	})
+}
+
+// MemorySource returns a source for fingerprints already in memory.
+// The fingerprints are not modified by the loader.
+func MemorySource(name string, fingerprints *Fingerprints) Source {
+	return Source{
+		Name: name,
+		load: func() (map[string]*Fingerprint, error) {
+			if fingerprints == nil {
+				return nil, nil
+			}
+			return fingerprints.Apps, nil
+		},
+	}
+}
+
+// parseFingerprints parses a fingerprints JSON document
+func parseFingerprints(data []byte, name string) (map[string]*Fingerprint, error) {
+	var fingerprintsStruct Fingerprints
+	if err := json.Unmarshal(data, &fingerprintsStruct); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", name, err)
+	}
+	return fingerprintsStruct.Apps, nil
+}
+
+// Loader loads fingerprints from an ordered list of sources, merging
+// the apps of each source into the apps of the earlier ones.
+type Loader struct {
+	sources []Source
+}
+
+// NewLoader creates a new loader for the sources, in order
+func NewLoader(sources ...Source) *Loader {
+	return &Loader{sources: sources}
+}
+
+// Add appends a source to the loader
+func (l *Loader) Add(source Source) *Loader {
+	l.sources = append(l.sources, source)
+	return l
+}
+
+// LoadReport describes where the loaded apps came from
+type LoadReport struct {
+	// Sources maps each app to the sources its final definition was built
+	// from, in load order. It has one source unless apps were deep merged.
+	Sources map[string][]string
+	// Overrides lists the apps defined by more than one source, in load order
+	Overrides []Override
+	// Diagnostics contains the fingerprint rules that could not be compiled
+	// and are ignored when matching, sorted by app. It is set when the
+	// fingerprints are loaded by a Wappalyze constructor.
+	Diagnostics []Diagnostic
+}
+
+// Override describes an app of a source conflicting with an app
+// of the same name loaded from earlier sources.
+type Override struct {
+	// App is the name of the app
+	App string
+	// Source is the name of the source overriding the app
+	Source string
+	// Previous contains the sources of the app before the override
+	Previous []string
+	// Strategy is the merge strategy applied. With MergeSkip the
+	// app of the source was ignored.
+	Strategy MergeStrategy
+}
+
+// Load loads the fingerprints from all the sources
+func (l *Loader) Load() (*Fingerprints, *LoadReport, error) {
+	if len(l.sources) == 0 {
+		return nil, nil, fmt.Errorf("no fingerprint sources to load")
+	}
+
+	merged := &Fingerprints{Apps: make(map[string]*Fingerprint)}
+	report := &LoadReport{Sources: make(map[string][]string)}
+
+	for _, source := range l.sources {
+		apps, err := source.load()
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not load fingerprints from %s: %w", source.Name, err)
+		}
+
+		for _, app := range sortedKeys(apps) {
+			fingerprint := apps[app]
+
+			existing, ok := merged.Apps[app]
+			if !ok {
+				merged.Apps[app] = fingerprint
+				report.Sources[app] = []string{source.Name}
+				continue
+			}
+
+			report.Overrides = append(report.Overrides, Override{
+				App:      app,
+				Source:   source.Name,
+				Previous: slices.Clone(report.Sources[app]),
+				Strategy: source.Strategy,
+			})
+			switch source.Strategy {
+			case MergeSkip:
+			case MergeDeep:
+				merged.Apps[app] = mergeFingerprint(existing, fingerprint)
+				report.Sources[app] = append(report.Sources[app], source.Name)
+			default:
+				merged.Apps[app] = fingerprint
+				report.Sources[app] = []string{source.Name}
+			}
+		}
+	}
+	return merged, report, nil
+}
+
+// mergeFingerprint returns a new fingerprint with the fields of
+// update deep merged into the fields of base.
+func mergeFingerprint(base, update *Fingerprint) *Fingerprint {
+	if base == nil {
+		return update
+	}
+	if update == nil {
+		return base
+	}
+
+	merged := &Fingerprint{
+		Cats:             mergeList(base.Cats, update.Cats),
+		CSS:              mergeList(base.CSS, update.CSS),
+		Cookies:          mergeMap(base.Cookies, update.Cookies),
+		JS:               mergeMap(base.JS, update.JS),
+		Headers:          mergeMap(base.Headers, update.Headers),
+		HTML:             mergeList(base.HTML, update.HTML),
+		Script:           mergeList(base.Script, update.Script),
+		ScriptSrc:        mergeList(base.ScriptSrc, update.ScriptSrc),
+		Implies:          mergeList(base.Implies, update.Implies),
+		Excludes:         mergeList(base.Excludes, update.Excludes),
+		Requires:         mergeList(base.Requires, update.Requires),
+		RequiresCategory: mergeList(base.RequiresCategory, update.RequiresCategory),
+		Description:      mergeScalar(base.Description, update.Description),
+		Website:          mergeScalar(base.Website, update.Website),
+		CPE:              mergeScalar(base.CPE, update.CPE),
+		Icon:             mergeScalar(base.Icon, update.Icon),
+		Browser:          base.Browser,
+	}
+	if update.Browser != nil {
+		merged.Browser = update.Browser
+	}
+
+	if base.Dom != nil || update.Dom != nil {
+		merged.Dom = make(map[string]map[string]interface{}, len(base.Dom)+len(update.Dom))
+		for selector, rules := range base.Dom {
+			merged.Dom[selector] = rules
+		}
+		for selector, rules := range update.Dom {
+			merged.Dom[selector] = mergeMap(merged.Dom[selector], rules)
+		}
+	}
+	if base.Meta != nil || update.Meta != nil {
+		merged.Meta = make(map[string][]string, len(base.Meta)+len(update.Meta))
+		for name, patterns := range base.Meta {
+			merged.Meta[name] = patterns
+		}
+		for name, patterns := range update.Meta {
+			merged.Meta[name] = mergeList(merged.Meta[name], patterns)
+		}
+	}
+	return merged
+}
+
+// mergeList appends the values of update missing from base to a copy of base
+func mergeList[T comparable](base, update []T) []T {
+	if len(update) == 0 {
+		return base
+	}
+	merged := slices.Clone(base)
+	for _, value := range update {
+		if !slices.Contains(merged, value) {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+// mergeMap returns a copy of base with the values of update set
+func mergeMap[V any](base, update map[string]V) map[string]V {
+	if len(update) == 0 {
+		return base
+	}
+	merged := make(map[string]V, len(base)+len(update))
+	maps.Copy(merged, base)
+	maps.Copy(merged, update)
+	return merged
+}
+
+// mergeScalar returns update if not empty, base otherwise
+func mergeScalar(base, update string) string {
+	if update != "" {
+		return update
+	}
+	return base
+}
+
+// cloneApps returns a deep copy of apps, so that the apps shared
+// by several loads can not be modified through one of them.
+func cloneApps(apps map[string]*Fingerprint) map[string]*Fingerprint {
+	cloned := make(map[string]*Fingerprint, len(apps))
+	for app, fingerprint := range apps {
+		cloned[app] = cloneFingerprint(fingerprint)
+	}
+	return cloned
+}
+
+// cloneFingerprint returns a deep copy of a fingerprint
+func cloneFingerprint(fingerprint *Fingerprint) *Fingerprint {
+	if fingerprint == nil {
+		return nil
+	}
+
+	cloned := &Fingerprint{
+		Cats:             slices.Clone(fingerprint.Cats),
+		CSS:              slices.Clone(fingerprint.CSS),
+		Cookies:          maps.Clone(fingerprint.Cookies),
+		JS:               maps.Clone(fingerprint.JS),
+		Headers:          maps.Clone(fingerprint.Headers),
+		HTML:             slices.Clone(fingerprint.HTML),
+		Script:           slices.Clone(fingerprint.Script),
+		ScriptSrc:        slices.Clone(fingerprint.ScriptSrc),
+		Implies:          slices.Clone(fingerprint.Implies),
+		Excludes:         slices.Clone(fingerprint.Excludes),
+		Requires:         slices.Clone(fingerprint.Requires),
+		RequiresCategory: slices.Clone(fingerprint.RequiresCategory),
+		Description:      fingerprint.Description,
+		Website:          fingerprint.Website,
+		CPE:              fingerprint.CPE,
+		Icon:             fingerprint.Icon,
+	}
+	if fingerprint.Dom != nil {
+		cloned.Dom = make(map[string]map[string]interface{}, len(fingerprint.Dom))
+		for selector, rules := range fingerprint.Dom {
+			cloned.Dom[selector], _ = cloneValue(rules).(map[string]interface{})
+		}
+	}
+	if fingerprint.Meta != nil {
+		cloned.Meta = make(map[string][]string, len(fingerprint.Meta))
+		for name, patterns := range fingerprint.Meta {
+			cloned.Meta[name] = slices.Clone(patterns)
+		}
+	}
+	if fingerprint.Browser != nil {
+		cloned.Browser = &BrowserDetection{
+			Detection: slices.Clone(fingerprint.Browser.Detection),
+			Version:   slices.Clone(fingerprint.Browser.Version),
+		}
+	}
+	return cloned
+}
+
+// cloneValue returns a deep copy of a decoded JSON value
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		cloned := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			cloned[key] = cloneValue(item)
+		}
+		return cloned
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		cloned := make([]interface{}, len(v))
+		for i, item := range v {
+			cloned[i] = cloneValue(item)
+		}
+		return cloned
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+package wappalyzer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader(t *testing.T) {
+	baseFingerprints := &Fingerprints{Apps: map[string]*Fingerprint{
+		"App": {
+			Cats:        []int{1},
+			Headers:     map[string]string{"x-app": ""},
+			HTML:        []string{"<app"},
+			Meta:        map[string][]string{"generator": {"app"}},
+			Description: "base",
+		},
+		"Other": {Description: "other"},
+	}}
+	base := MemorySource("base", baseFingerprints)
+	update := &Fingerprints{Apps: map[string]*Fingerprint{
+		"App": {
+			Cats:    []int{1, 2},
+			Headers: map[string]string{"x-app-version": ""},
+			HTML:    []string{"<app-root"},
+			Meta:    map[string][]string{"generator": {"app ([\\d.]+)\\;version:\\1"}},
+			Website: "https://example.com",
+		},
+	}}
+
+	t.Run("replace", func(t *testing.T) {
+		loaded, report, err := NewLoader(base, MemorySource("update", update)).Load()
+		require.Nil(t, err, "could not load fingerprints")
+		require.Equal(t, update.Apps["App"], loaded.Apps["App"], "could not replace app")
+		require.Equal(t, []string{"update"}, report.Sources["App"], "could not get app source")
+		require.Equal(t, []string{"base"}, report.Sources["Other"], "could not get app source")
+		require.Equal(t, []Override{{App: "App", Source: "update", Previous: []string{"base"}, Strategy: MergeReplace}}, report.Overrides, "could not get overrides")
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		loaded, report, err := NewLoader(base, MemorySource("update", update).WithStrategy(MergeSkip)).Load()
+		require.Nil(t, err, "could not load fingerprints")
+		require.Equal(t, "base", loaded.Apps["App"].Description, "could not skip app")
+		require.Equal(t, []string{"base"}, report.Sources["App"], "could not get app source")
+		require.Len(t, report.Overrides, 1, "could not get skipped override")
+	})
+
+	t.Run("deep", func(t *testing.T) {
+		loaded, report, err := NewLoader(base, MemorySource("update", update).WithStrategy(MergeDeep)).Load()
+		require.Nil(t, err, "could not load fingerprints")
+
+		app := loaded.Apps["App"]
+		require.Equal(t, []int{1, 2}, app.Cats, "could not merge cats")
+		require.Equal(t, map[string]string{"x-app": "", "x-app-version": ""}, app.Headers, "could not merge headers")
+		require.Equal(t, []string{"<app", "<app-root"}, app.HTML, "could not merge html")
+		require.Equal(t, []string{"app", "app ([\\d.]+)\\;version:\\1"}, app.Meta["generator"], "could not merge meta")
+		require.Equal(t, "base", app.Description, "could not keep description")
+		require.Equal(t, "https://example.com", app.Website, "could not merge website")
+		require.Equal(t, []string{"base", "update"}, report.Sources["App"], "could not get app sources")
+		require.Equal(t, []string{"<app"}, baseFingerprints.Apps["App"].HTML, "could modify source fingerprints")
+	})
+}
+
+func TestNewFromFileSupersede(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "fingerprints.json")
+	err := os.WriteFile(filePath, []byte(`{"apps": {"WordPress": {"description": "custom"}, "Custom App": {"headers": {"x-custom": ""}}}}`), 0644)
+	require.Nil(t, err, "could not write fingerprints file")
+
+	t.Run("supersede", func(t *testing.T) {
+		wappalyzer, err := NewFromFile(filePath, true, true)
+		require.Nil(t, err, "could not create wappalyzer")
+		require.Equal(t, "custom", wappalyzer.GetFingerprints().Apps["WordPress"].Description, "could not supersede embedded app")
+		require.Contains(t, wappalyzer.GetFingerprints().Apps, "Custom App", "could not load file app")
+	})
+
+	t.Run("no-supersede", func(t *testing.T) {
+		wappalyzer, err := NewFromFile(filePath, true, false)
+		require.Nil(t, err, "could not create wappalyzer")
+		require.NotEqual(t, "custom", wappalyzer.GetFingerprints().Apps["WordPress"].Description, "could overwrite embedded app")
+		require.Contains(t, wappalyzer.GetFingerprints().Apps, "Custom App", "could not load file app")
+		require.Equal(t, []string{"embedded"}, wappalyzer.LoadReport().Sources["WordPress"], "could not get app source")
+		require.Equal(t, []string{filePath}, wappalyzer.LoadReport().Sources["Custom App"], "could not get app source")
+	})
+}
+
+func TestNewFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"cms.json":               {Data: []byte(`{"apps": {"Custom CMS": {"headers": {"x-custom-cms": ""}}}}`)},
+		"servers/servers.json":   {Data: []byte(`{"apps": {"Custom Server": {"headers": {"server": "custom-server/([\\d.]+)\\;version:\\1"}}}}`)},
+		"servers/overrides.json": {Data: []byte(`{"apps": {"Custom CMS": {"headers": {"x-cms": ""}}}}`)},
+		"README.md":              {Data: []byte(`not a fingerprints file`)},
+	}
+
+	wappalyzer, err := NewFromFS(fsys)
+	require.Nil(t, err, "could not create wappalyzer")
+	require.Len(t, wappalyzer.GetFingerprints().Apps, 2, "could not load fingerprints")
+	require.Equal(t, map[string]string{"x-cms": ""}, wappalyzer.GetFingerprints().Apps["Custom CMS"].Headers, "could not load files in lexical order")
+
+	fingerprints := wappalyzer.Fingerprint(map[string][]string{"Server": {"custom-server/1.2"}}, nil)
+	require.Equal(t, map[string]struct{}{"Custom Server:1.2": {}}, fingerprints, "could not get correct match")
+
+	_, err = NewFromFS(fstest.MapFS{})
+	require.NotNil(t, err, "could load empty file system")
+
+	_, err = NewFromFS(fstest.MapFS{"invalid.json": {Data: []byte(`{"apps": {`)}})
+	require.NotNil(t, err, "could load invalid file")
+}
+
+func TestNewFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "custom.json"), []byte(`{"apps": {"Custom App": {"headers": {"x-custom": ""}}}}`), 0644)
+	require.Nil(t, err, "could not write fingerprints file")
+
+	wappalyzer, err := NewFromDirectory(dir)
+	require.Nil(t, err, "could not create wappalyzer")
+	require.Contains(t, wappalyzer.GetFingerprints().Apps, "Custom App", "could not load directory app")
+	require.Equal(t, []string{dir}, wappalyzer.LoadReport().Sources["Custom App"], "could not get app source")
+}
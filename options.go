@@ -0,0 +1,84 @@
+package wappalyzer
+
+// Option is an option for configuring the tech detection instance
+type Option func(*Wappalyze)
+
+// WithEvidence enables recording of the evidence trail for each detection.
+//
+// Evidence contains the part, key, raw pattern, matched substring and
+// pattern confidence for every rule that fired, and is returned in
+// Detection.Evidence.
+func WithEvidence() Option {
+	return func(s *Wappalyze) {
+		s.evidence = true
+	}
+}
+
+// WithMinConfidence sets the minimum accumulated confidence (0-100)
+// a technology needs to be reported. Technologies only matched by
+// low confidence patterns (e.g. "\;confidence:25") are suppressed.
+func WithMinConfidence(confidence int) Option {
+	return func(s *Wappalyze) {
+		s.minConfidence = confidence
+	}
+}
+
+// WithMaxScriptBytes caps the number of inline <script> bytes matched
+// against the scripts patterns, and scanned for js globals, per page.
+// Zero means no limit.
+func WithMaxScriptBytes(maxBytes int) Option {
+	return func(s *Wappalyze) {
+		s.maxScriptBytes = maxBytes
+	}
+}
+
+// WithResourceFetcher sets a fetcher used to retrieve external scripts
+// and stylesheets referenced by the page, which are then included in the
+// static analysis of the js rules and the matching of the css rules.
+// Without a fetcher only inline scripts and styles are analyzed.
+func WithResourceFetcher(fetcher ResourceFetcher) Option {
+	return func(s *Wappalyze) {
+		s.resourceFetcher = fetcher
+	}
+}
+
+// WithMaxBodySize sets the maximum number of body bytes read by
+// FingerprintReader. The rest of the body is left unread. Zero uses
+// the default of 10 MiB.
+func WithMaxBodySize(size int64) Option {
+	return func(s *Wappalyze) {
+		s.maxBodySize = size
+	}
+}
+
+// WithCache loads the fingerprints through a cache file at the path,
+// written on the first load. Later instances created with unchanged
+// fingerprint sources decode the cache file instead of parsing the
+// fingerprints JSON. The cache file also holds the prefilters of the
+// instance, the other indexes are built on first use. See
+// Loader.LoadCached.
+func WithCache(cachePath string) Option {
+	return func(s *Wappalyze) {
+		s.cachePath = cachePath
+	}
+}
+
+// WithPatternValidation compiles the regexes of all the fingerprint
+// patterns when loading them, instead of on their first evaluation,
+// and fails the loading with a DiagnosticsError listing the invalid
+// patterns if any.
+func WithPatternValidation() Option {
+	return func(s *Wappalyze) {
+		s.validatePatterns = true
+	}
+}
+
+// WithStrictMode fails the loading of fingerprints having any rule that
+// could not be compiled, such as an invalid regex or dom selector, with
+// a DiagnosticsError. Without it the invalid rules are ignored and
+// reported in the Diagnostics of the LoadReport.
+func WithStrictMode() Option {
+	return func(s *Wappalyze) {
+		s.strict = true
+	}
+}
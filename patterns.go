@@ -3,14 +3,25 @@ package wappalyzer
 import (
 	"fmt"
 	"regexp"
+	"regexp/syntax"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 // ParsedPattern encapsulates a regular expression with
 // additional metadata for confidence and version extraction.
+//
+// The regular expression is compiled on first evaluation, once,
+// and the pattern is safe for concurrent use.
 type ParsedPattern struct {
 	regex *regexp.Regexp
+	// expr is the regular expression of the pattern, compiled to regex
+	expr      string
+	regexOnce sync.Once
+	regexErr  error
+	// raw is the pattern string as present in the fingerprint
+	raw string
 
 	Confidence int
 	Version    string
@@ -27,10 +38,21 @@ const (
 	verCap2Limited = `((?:\d{1,20}\.){1,20}\d{1,20})`
 )
 
-// ParsePattern extracts information from a pattern, supporting both regex and simple patterns
+// ParsePattern extracts information from a pattern, supporting both regex and simple patterns.
+// The regular expression of the pattern is compiled immediately.
 func ParsePattern(pattern string) (*ParsedPattern, error) {
+	p := parsePattern(pattern)
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parsePattern extracts information from a pattern without compiling
+// its regular expression, which is compiled on first evaluation.
+func parsePattern(pattern string) *ParsedPattern {
 	parts := strings.Split(pattern, "\\;")
-	p := &ParsedPattern{Confidence: 100}
+	p := &ParsedPattern{Confidence: 100, raw: pattern}
 
 	if parts[0] == "" {
 		p.SkipRegex = true
@@ -55,11 +77,7 @@ func ParsePattern(pattern string) (*ParsedPattern, error) {
 			regexPattern = strings.ReplaceAll(regexPattern, verCap1Fill, verCap1Limited)
 			regexPattern = strings.ReplaceAll(regexPattern, verCap2Fill, verCap2Limited)
 
-			var err error
-			p.regex, err = regexp.Compile("(?i)" + regexPattern)
-			if err != nil {
-				return nil, err
-			}
+			p.expr = "(?i)" + regexPattern
 		} else {
 			keyValue := strings.SplitN(part, ":", 2)
 			if len(keyValue) < 2 {
@@ -80,23 +98,60 @@ func ParsePattern(pattern string) (*ParsedPattern, error) {
 			}
 		}
 	}
-	return p, nil
+	return p
+}
+
+// compile compiles the regular expression of the pattern if not already
+// compiled, returning the compilation error if any.
+func (p *ParsedPattern) compile() error {
+	p.regexOnce.Do(func() {
+		if p.SkipRegex {
+			return
+		}
+		p.regex, p.regexErr = regexp.Compile(p.expr)
+	})
+	return p.regexErr
+}
+
+// validate parses the regular expression of the pattern without
+// compiling it, returning the error its compilation would return.
+func (p *ParsedPattern) validate() error {
+	if p.SkipRegex {
+		return nil
+	}
+	if _, err := syntax.Parse(p.expr, syntax.Perl); err != nil {
+		return err
+	}
+	return nil
 }
 
 func (p *ParsedPattern) Evaluate(target string) (bool, string) {
+	valid, version, _ := p.evaluate(target)
+	return valid, version
+}
+
+// evaluate evaluates the pattern against the target and also returns
+// the matched substring. Patterns without a regex match the whole target.
+func (p *ParsedPattern) evaluate(target string) (bool, string, string) {
 	if p.SkipRegex {
-		return true, ""
+		return true, "", target
 	}
-	if p.regex == nil {
-		return false, ""
+	// Patterns with an invalid regex never match
+	if p.compile() != nil {
+		return false, "", ""
 	}
 
 	submatches := p.regex.FindStringSubmatch(target)
 	if len(submatches) == 0 {
-		return false, ""
+		return false, "", ""
 	}
 	extractedVersion, _ := p.extractVersion(submatches)
-	return true, extractedVersion
+	return true, extractedVersion, submatches[0]
+}
+
+// Raw returns the pattern string as present in the fingerprint
+func (p *ParsedPattern) Raw() string {
+	return p.raw
 }
 
 // extractVersion uses the provided pattern to extract version information from a target string.
@@ -1,6 +1,11 @@
 package wappalyzer
 
-import "testing"
+import (
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
 
 func TestParsePattern(t *testing.T) {
 	tests := []struct {
@@ -189,3 +194,53 @@ func TestExtractVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestLazyPattern(t *testing.T) {
+	pattern := parsePattern("jquery-([0-9.]+)\\.js\\;version:\\1")
+	if pattern.regex != nil {
+		t.Fatal("Expected regex to be compiled on first evaluation")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if match, ver := pattern.Evaluate("/js/jquery-3.6.0.js"); !match || ver != "3.6.0" {
+				t.Errorf("Expected match with version 3.6.0, got %v %s", match, ver)
+			}
+		}()
+	}
+	wg.Wait()
+
+	invalid := parsePattern("(?!angular\\.io)angular\\.js")
+	if match, _ := invalid.Evaluate("angular.js"); match {
+		t.Error("Expected invalid pattern to never match")
+	}
+	if invalid.compile() == nil {
+		t.Error("Expected invalid pattern to return its compilation error")
+	}
+	if _, err := ParsePattern("(?!angular\\.io)angular\\.js"); err == nil {
+		t.Error("Expected ParsePattern to fail for invalid pattern")
+	}
+}
+
+func TestPatternValidation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"custom.json": {Data: []byte(`{"apps": {"Custom App": {"headers": {"server": "custom", "x-custom": "(?!other)custom"}}}}`)},
+	}
+
+	wappalyzer, err := NewFromFS(fsys)
+	if err != nil {
+		t.Fatal("Failed to load fingerprints without validation:", err)
+	}
+	fingerprints := wappalyzer.Fingerprint(map[string][]string{"Server": {"custom"}}, nil)
+	if _, ok := fingerprints["Custom App"]; !ok {
+		t.Error("Expected valid patterns of an app with an invalid pattern to match")
+	}
+
+	_, err = NewFromFS(fsys, WithPatternValidation())
+	if err == nil || !strings.Contains(err.Error(), `invalid header pattern "(?!other)custom" for Custom App (x-custom)`) {
+		t.Errorf("Expected invalid pattern error, got %v", err)
+	}
+}
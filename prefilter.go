@@ -0,0 +1,420 @@
+package wappalyzer
+
+import (
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/projectdiscovery/wappalyzergo/internal/ahocorasick"
+)
+
+const (
+	// prefilterVersion is the version of the prefilter algorithm, part of
+	// the key of the fingerprints cache holding the prefilters. It must be
+	// increased whenever the literals extracted from the patterns or the
+	// matcher built from them change.
+	prefilterVersion = 1
+	// minPrefilterLiteralLength is the minimum length of the literals used
+	// by the prefilter. Patterns only requiring shorter literals are always
+	// evaluated, as such literals are found in nearly every input.
+	minPrefilterLiteralLength = 3
+	// maxPrefilterAlternatives is the maximum number of alternative
+	// literals tracked for a single regular expression node.
+	maxPrefilterAlternatives = 16
+)
+
+// prefilterParts are the parts matched with matchString that are prefiltered
+var prefilterParts = []part{htmlPart, scriptPart, scriptsPart, cssPart}
+
+// prefilter narrows an input down to the apps that can possibly match it.
+//
+// Every regex of a part is reduced to a set of literals, at least one of
+// which is contained in any string it matches. The literals of all the
+// apps are searched at once with an Aho-Corasick automaton, and only the
+// apps whose literals were found are evaluated, along with the apps that
+// have patterns without usable literals.
+type prefilter struct {
+	matcher *ahocorasick.Matcher
+	// literalApps contains the apps requiring each literal of the matcher
+	literalApps [][]string
+	// always contains the apps that are evaluated for every input
+	always []string
+	// apps contains all the apps with patterns for the part
+	apps []string
+}
+
+// getPrefilter returns the prefilter for a part, if any, building it if needed
+func (f *CompiledFingerprints) getPrefilter(part part) *prefilter {
+	if !slices.Contains(prefilterParts, part) {
+		return nil
+	}
+	f.indexes.prefilterOnce[part].Do(func() {
+		if f.indexes.prefilters[part] == nil {
+			f.indexes.prefilters[part] = newPrefilter(f.Apps, part)
+		}
+	})
+	return f.indexes.prefilters[part]
+}
+
+// newPrefilter builds the prefilter for a part of the apps
+func newPrefilter(apps map[string]*CompiledFingerprint, part part) *prefilter {
+	filter := &prefilter{}
+
+	literalIndexes := make(map[string]int)
+	var literals []string
+	for _, app := range sortedKeys(apps) {
+		patterns := apps[app].partPatterns(part)
+		if len(patterns) == 0 {
+			continue
+		}
+		filter.apps = append(filter.apps, app)
+
+		appLiterals := make(map[string]struct{})
+		var always bool
+		for _, pattern := range patterns {
+			required := pattern.requiredLiterals()
+			if required == nil {
+				always = true
+				break
+			}
+			for _, literal := range required {
+				appLiterals[literal] = struct{}{}
+			}
+		}
+		if always {
+			filter.always = append(filter.always, app)
+			continue
+		}
+
+		for _, literal := range sortedKeys(appLiterals) {
+			index, ok := literalIndexes[literal]
+			if !ok {
+				index = len(literals)
+				literalIndexes[literal] = index
+				literals = append(literals, literal)
+				filter.literalApps = append(filter.literalApps, nil)
+			}
+			filter.literalApps[index] = append(filter.literalApps[index], app)
+		}
+	}
+	filter.matcher = ahocorasick.New(literals)
+	return filter
+}
+
+// candidates returns the apps that can possibly match the data
+func (p *prefilter) candidates(data string) []string {
+	// Case-insensitive regexes match the Kelvin sign and the long s as k and s,
+	// which the ASCII folding of the automaton does not, so skip filtering.
+	if strings.ContainsAny(data, "Kſ") {
+		return p.apps
+	}
+
+	found := p.matcher.FindAll(data)
+	if len(found) == 0 {
+		return p.always
+	}
+
+	candidates := make(map[string]struct{}, len(p.always)+len(found))
+	for _, app := range p.always {
+		candidates[app] = struct{}{}
+	}
+	for _, index := range found {
+		for _, app := range p.literalApps[index] {
+			candidates[app] = struct{}{}
+		}
+	}
+	return sortedKeys(candidates)
+}
+
+// partPatterns returns the patterns of a fingerprint for a prefiltered part
+func (f *CompiledFingerprint) partPatterns(part part) []*ParsedPattern {
+	switch part {
+	case htmlPart:
+		return f.html
+	case scriptPart:
+		return f.scriptSrc
+	case scriptsPart:
+		return f.script
+	case cssPart:
+		return f.css
+	}
+	return nil
+}
+
+// requiredLiterals returns a set of lowercase literals at least one of
+// which is contained in every string matched by the pattern, or nil if
+// no such set could be extracted.
+func (p *ParsedPattern) requiredLiterals() []string {
+	if p.SkipRegex {
+		return nil
+	}
+	// The expression is parsed without compiling the pattern regex,
+	// so invalid patterns are never candidates and never match.
+	re, err := syntax.Parse(p.expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	required := analyzeLiterals(re).literals()
+	if len(required) == 0 {
+		return nil
+	}
+	for _, literal := range required {
+		if len(literal) < minPrefilterLiteralLength {
+			return nil
+		}
+	}
+	return required
+}
+
+// literalInfo describes the literals of a regular expression node
+type literalInfo struct {
+	// exact contains all the strings the node can match, if known
+	exact []string
+	// required contains literals one of which is contained
+	// in every string the node matches, if known
+	required []string
+}
+
+// literals returns the best set of required literals for the node
+func (l literalInfo) literals() []string {
+	if l.exact != nil && !slicesContainEmpty(l.exact) {
+		return betterLiterals(l.exact, l.required)
+	}
+	return l.required
+}
+
+// analyzeLiterals extracts the exact and required literals of a node
+func analyzeLiterals(re *syntax.Regexp) literalInfo {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return literalInfo{exact: []string{""}}
+	case syntax.OpLiteral:
+		if literal, ok := asciiLower(re.Rune); ok {
+			return literalInfo{exact: []string{literal}}
+		}
+		return literalInfo{required: longestASCIIRun(re.Rune)}
+	case syntax.OpCharClass:
+		return literalInfo{exact: charClassLiterals(re.Rune)}
+	case syntax.OpCapture:
+		return analyzeLiterals(re.Sub[0])
+	case syntax.OpQuest:
+		sub := analyzeLiterals(re.Sub[0])
+		if sub.exact == nil || len(sub.exact) >= maxPrefilterAlternatives {
+			return literalInfo{}
+		}
+		return literalInfo{exact: appendUnique(sub.exact, "")}
+	case syntax.OpPlus:
+		return literalInfo{required: analyzeLiterals(re.Sub[0]).literals()}
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return literalInfo{}
+		}
+		sub := analyzeLiterals(re.Sub[0])
+		if re.Min == 1 && re.Max == 1 {
+			return sub
+		}
+		return literalInfo{required: sub.literals()}
+	case syntax.OpConcat:
+		return analyzeConcat(re.Sub)
+	case syntax.OpAlternate:
+		return analyzeAlternate(re.Sub)
+	}
+	return literalInfo{}
+}
+
+// analyzeConcat extracts the literals of a concatenation. Consecutive
+// exact nodes are joined into longer literals.
+func analyzeConcat(subs []*syntax.Regexp) literalInfo {
+	var best []string
+	current := []string{""}
+	allExact := true
+
+	flush := func() {
+		if current != nil && !slicesContainEmpty(current) {
+			best = betterLiterals(best, current)
+		}
+	}
+	for _, sub := range subs {
+		info := analyzeLiterals(sub)
+		if info.exact != nil {
+			if product := crossProduct(current, info.exact); product != nil {
+				current = product
+				continue
+			}
+			// Too many alternatives, start over from this node
+			flush()
+			allExact = false
+			current = info.exact
+			continue
+		}
+
+		flush()
+		allExact = false
+		current = []string{""}
+		best = betterLiterals(best, info.required)
+	}
+	flush()
+
+	if allExact {
+		return literalInfo{exact: current, required: best}
+	}
+	return literalInfo{required: best}
+}
+
+// analyzeAlternate extracts the literals of an alternation
+func analyzeAlternate(subs []*syntax.Regexp) literalInfo {
+	var exact, required []string
+	exactOK, requiredOK := true, true
+	for _, sub := range subs {
+		info := analyzeLiterals(sub)
+		if exactOK {
+			if info.exact == nil {
+				exactOK = false
+			}
+			for _, literal := range info.exact {
+				exact = appendUnique(exact, literal)
+			}
+			if len(exact) > maxPrefilterAlternatives {
+				exactOK = false
+			}
+		}
+		if requiredOK {
+			// A branch without literals makes the alternation unfilterable
+			subRequired := info.literals()
+			if len(subRequired) == 0 {
+				requiredOK = false
+			}
+			for _, literal := range subRequired {
+				required = appendUnique(required, literal)
+			}
+		}
+	}
+	if exactOK {
+		return literalInfo{exact: exact}
+	}
+	if requiredOK {
+		return literalInfo{required: required}
+	}
+	return literalInfo{}
+}
+
+// betterLiterals returns the more selective of two literal sets: the one
+// with the longest shortest literal, then the one with fewer literals.
+func betterLiterals(a, b []string) []string {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	minA, minB := shortestLength(a), shortestLength(b)
+	if minA != minB {
+		if minA > minB {
+			return a
+		}
+		return b
+	}
+	if len(b) < len(a) {
+		return b
+	}
+	return a
+}
+
+// crossProduct returns every concatenation of a prefix and a suffix,
+// or nil if there would be too many of them.
+func crossProduct(prefixes, suffixes []string) []string {
+	if len(prefixes)*len(suffixes) > maxPrefilterAlternatives {
+		return nil
+	}
+	product := make([]string, 0, len(prefixes)*len(suffixes))
+	for _, prefix := range prefixes {
+		for _, suffix := range suffixes {
+			product = appendUnique(product, prefix+suffix)
+		}
+	}
+	return product
+}
+
+// charClassLiterals returns the lowercase characters of a small ASCII
+// character class, or nil if the class is too large.
+func charClassLiterals(ranges []rune) []string {
+	var literals []string
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if ranges[i+1]-ranges[i] >= maxPrefilterAlternatives {
+			return nil
+		}
+		for r := ranges[i]; r <= ranges[i+1]; r++ {
+			if r >= utf8.RuneSelf {
+				return nil
+			}
+			literals = appendUnique(literals, strings.ToLower(string(r)))
+			if len(literals) > maxPrefilterAlternatives {
+				return nil
+			}
+		}
+	}
+	return literals
+}
+
+// asciiLower returns the lowercase string of the runes if they are all ASCII
+func asciiLower(runes []rune) (string, bool) {
+	builder := &strings.Builder{}
+	for _, r := range runes {
+		if r >= utf8.RuneSelf {
+			return "", false
+		}
+		builder.WriteRune(r)
+	}
+	return strings.ToLower(builder.String()), true
+}
+
+// longestASCIIRun returns the longest run of ASCII runes as a literal set
+func longestASCIIRun(runes []rune) []string {
+	var longest, current []rune
+	for _, r := range runes {
+		if r >= utf8.RuneSelf {
+			current = current[:0:0]
+			continue
+		}
+		current = append(current, r)
+		if len(current) > len(longest) {
+			longest = current
+		}
+	}
+	if len(longest) == 0 {
+		return nil
+	}
+	return []string{strings.ToLower(string(longest))}
+}
+
+// shortestLength returns the length of the shortest literal
+func shortestLength(literals []string) int {
+	shortest := len(literals[0])
+	for _, literal := range literals[1:] {
+		shortest = min(shortest, len(literal))
+	}
+	return shortest
+}
+
+// slicesContainEmpty returns true if the literals contain the empty string
+func slicesContainEmpty(literals []string) bool {
+	for _, literal := range literals {
+		if literal == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique appends a literal if not already present
+func appendUnique(literals []string, literal string) []string {
+	for _, existing := range literals {
+		if existing == literal {
+			return literals
+		}
+	}
+	return append(literals, literal)
+}
@@ -0,0 +1,191 @@
+package wappalyzer
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{pattern: `cdn\.example\.com/app\.js`, expected: []string{"cdn.example.com/app.js"}},
+		{pattern: `https?://Example\.com`, expected: []string{"https://example.com", "http://example.com"}},
+		{pattern: `jquery[.-]([\d.]*\d)[^/]*\.js`, expected: []string{"jquery-", "jquery."}},
+		{pattern: `/wp-content/plugins/foo/.+\.js(?:\?ver=([\d.]+))?\;version:\1`, expected: []string{"/wp-content/plugins/foo/"}},
+		{pattern: `(?:vue|react)\.min\.js`, expected: []string{"vue.min.js", "react.min.js"}},
+		{pattern: `\d+\.\d+`},
+		{pattern: `a|bc`},
+		{pattern: ``},
+	}
+	for _, test := range tests {
+		pattern, err := ParsePattern(test.pattern)
+		require.Nil(t, err, "could not parse pattern")
+		require.Equal(t, test.expected, pattern.requiredLiterals(), "could not get correct literals for %s", test.pattern)
+	}
+}
+
+func TestPrefilter(t *testing.T) {
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+	fingerprints := wappalyzer.GetCompiledFingerprints()
+
+	inputs := map[part][]string{
+		scriptPart: {
+			"https://code.jquery.com/jquery-3.6.0.min.js",
+			"/wp-content/plugins/woocommerce/assets/js/frontend/cart.min.js?ver=8.2.1",
+			"https://www.googletagmanager.com/gtag/js?id=G-XXXX",
+			"/static/Kendo.js",
+		},
+		htmlPart: {
+			`<div id="__next"><link rel="stylesheet" href="/wp-content/themes/x/style.css"></div>`,
+			`<!-- This site is optimized with the Yoast SEO plugin v21.5 -->`,
+		},
+		scriptsPart: {`window.dataLayer = window.dataLayer || []; gtag('config', 'G-XXXX');`},
+		cssPart:     {`.MuiPaper-root { color: red; }`},
+	}
+	for part, values := range inputs {
+		for _, value := range values {
+			require.Equal(t,
+				matchResultNames(fingerprints.matchStringAll(value, part, true)),
+				matchResultNames(fingerprints.matchString(value, part, true)),
+				"prefiltered results differ for %s %q", part, value,
+			)
+		}
+	}
+}
+
+func TestPrefilterRandom(t *testing.T) {
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+	fingerprints := wappalyzer.GetCompiledFingerprints()
+
+	patterns := make(map[part][]*ParsedPattern)
+	for _, app := range sortedKeys(fingerprints.Apps) {
+		for _, part := range prefilterParts {
+			patterns[part] = append(patterns[part], fingerprints.Apps[app].partPatterns(part)...)
+		}
+	}
+
+	// Inputs are built from the literals of random patterns and some
+	// surrounding text, so that most of them match some apps.
+	random := rand.New(rand.NewSource(1))
+	fillers := []string{"", " ", "/", "-1.2.3", ".min.js", "<div class=\"app\">", "?ver=4.5"}
+	var matched int
+	for i := 0; i < 200; i++ {
+		part := prefilterParts[random.Intn(len(prefilterParts))]
+
+		builder := &strings.Builder{}
+		for j := random.Intn(4) + 1; j > 0; j-- {
+			pattern := patterns[part][random.Intn(len(patterns[part]))]
+			fragment := pattern.raw
+			if literals := pattern.requiredLiterals(); len(literals) > 0 && random.Intn(4) > 0 {
+				fragment = literals[random.Intn(len(literals))]
+			}
+			builder.WriteString(fillers[random.Intn(len(fillers))])
+			builder.WriteString(fragment)
+			builder.WriteString(fillers[random.Intn(len(fillers))])
+		}
+		value := strings.ToLower(builder.String())
+
+		expected := matchResultNames(fingerprints.matchStringAll(value, part, false))
+		require.Equal(t, expected, matchResultNames(fingerprints.matchString(value, part, false)), "prefiltered results differ for %s %q", part, value)
+		if len(expected) > 0 {
+			matched++
+		}
+	}
+	require.Greater(t, matched, 100, "could not generate matching inputs")
+}
+
+// matchResultNames returns the sorted app and version of the results
+func matchResultNames(results []matchPartResult) []string {
+	names := make([]string, 0, len(results))
+	for _, result := range results {
+		names = append(names, FormatAppVersion(result.application, result.version))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// benchmarkScriptSources is a sample of script sources from a large page
+var benchmarkScriptSources = []string{
+	"https://code.jquery.com/jquery-3.6.0.min.js",
+	"https://www.googletagmanager.com/gtag/js?id=G-XXXX",
+	"/wp-includes/js/jquery/jquery-migrate.min.js?ver=3.4.1",
+	"/wp-content/plugins/woocommerce/assets/js/frontend/cart.min.js?ver=8.2.1",
+	"/wp-content/themes/astra/assets/js/minified/frontend.min.js?ver=4.5.2",
+	"https://cdn.jsdelivr.net/npm/bootstrap@5.3.2/dist/js/bootstrap.bundle.min.js",
+	"https://connect.facebook.net/en_US/fbevents.js",
+	"https://static.hotjar.com/c/hotjar-123.js?sv=6",
+	"/_next/static/chunks/main-abc123.js",
+	"https://js.stripe.com/v3/",
+}
+
+func BenchmarkMatchString(b *testing.B) {
+	wappalyzer, err := New()
+	require.Nil(b, err, "could not create wappalyzer")
+	fingerprints := wappalyzer.GetCompiledFingerprints()
+
+	html := strings.Repeat(`<div class="container"><a href="/product/1">Product</a><img src="/img/1.png"></div>`, 500)
+
+	b.Run("scriptSrc/prefilter", func(b *testing.B) {
+		fingerprints.getPrefilter(scriptPart)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, source := range benchmarkScriptSources {
+				fingerprints.matchString(source, scriptPart, false)
+			}
+		}
+	})
+	b.Run("scriptSrc/full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, source := range benchmarkScriptSources {
+				fingerprints.matchStringAll(source, scriptPart, false)
+			}
+		}
+	})
+	b.Run("html/prefilter", func(b *testing.B) {
+		fingerprints.getPrefilter(htmlPart)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			fingerprints.matchString(html, htmlPart, false)
+		}
+	})
+	b.Run("html/full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fingerprints.matchStringAll(html, htmlPart, false)
+		}
+	})
+}
+
+func BenchmarkMatchDOM(b *testing.B) {
+	wappalyzer, err := New()
+	require.Nil(b, err, "could not create wappalyzer")
+	fingerprints := wappalyzer.GetCompiledFingerprints()
+
+	// About 4,000 elements, the size of a large page
+	body := "<html><body>" + strings.Repeat(`<div class="card"><h2 id="title">Product</h2><p>Some <b>text</b></p><a class="btn btn-primary" href="/product/1">Buy</a><img src="/img/1.png" alt=""></div>`, 570) + "</body></html>"
+	elements, err := parseDocument(context.Background(), []byte(body))
+	require.Nil(b, err, "could not parse document")
+
+	b.Run("indexed", func(b *testing.B) {
+		fingerprints.getDOMIndex()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = fingerprints.matchDOM(context.Background(), elements, false)
+		}
+	})
+	b.Run("full", func(b *testing.B) {
+		index := newDOMIndex(fingerprints.Apps, false)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = fingerprints.matchDOMIndex(context.Background(), elements, index, false)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package wappalyzer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultReloadInterval is the default interval between checks of the watched path
+const defaultReloadInterval = 30 * time.Second
+
+// ReloaderOptions contains the options of a Reloader
+type ReloaderOptions struct {
+	// Interval is the interval between checks of the watched path
+	// for changes. Defaults to 30 seconds.
+	Interval time.Duration
+	// LoadEmbedded layers the watched fingerprints over the embedded
+	// fingerprints, replacing the embedded apps with the same name.
+	LoadEmbedded bool
+	// Validate is called with a newly loaded instance before it is
+	// swapped in. The instance is discarded if it returns an error.
+	Validate func(*Wappalyze) error
+	// OnReload is called after every reload triggered by a change of
+	// the watched path, with the error of the reload if it failed.
+	OnReload func(err error)
+}
+
+// Reloader is a tech detection instance reloading its fingerprints
+// from a file or a directory when they change.
+//
+// A reload loads and compiles the new fingerprints in the background
+// and swaps them in atomically once validated. A failed reload keeps
+// the fingerprints in use, and is not retried until the path changes
+// again. Fingerprinting calls running during a swap complete with the
+// fingerprints they started with.
+type Reloader struct {
+	path     string
+	options  []Option
+	settings ReloaderOptions
+
+	current atomic.Pointer[Wappalyze]
+
+	// mutex serializes the reloads
+	mutex sync.Mutex
+	// state is the state of the watched path of the current instance
+	state string
+	// attempted is the state of the watched path of the last reload,
+	// successful or not, so that a failed reload is not retried until
+	// the path changes again.
+	attempted string
+}
+
+// NewReloader creates a new reloading tech detection instance for a
+// fingerprints JSON file or a directory of fingerprints JSON files.
+// The options are applied to every loaded instance.
+//
+// The initial load must succeed. Call Watch to start watching the path.
+func NewReloader(path string, settings ReloaderOptions, options ...Option) (*Reloader, error) {
+	if settings.Interval <= 0 {
+		settings.Interval = defaultReloadInterval
+	}
+	reloader := &Reloader{
+		path:     path,
+		options:  options,
+		settings: settings,
+	}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Current returns the instance in use.
+//
+// The instance is never modified by a reload, so callers needing several
+// calls to work on the same fingerprints should use the returned instance
+// instead of the reloader.
+func (r *Reloader) Current() *Wappalyze {
+	return r.current.Load()
+}
+
+// Fingerprint identifies technologies on a target using the instance in use.
+// See Wappalyze.Fingerprint.
+func (r *Reloader) Fingerprint(headers map[string][]string, body []byte) map[string]struct{} {
+	return r.Current().Fingerprint(headers, body)
+}
+
+// FingerprintDetailed identifies technologies on a target using the
+// instance in use. See Wappalyze.FingerprintDetailed.
+func (r *Reloader) FingerprintDetailed(headers map[string][]string, body []byte) []Detection {
+	return r.Current().FingerprintDetailed(headers, body)
+}
+
+// Reload loads the fingerprints from the watched path and swaps them in
+// if they are valid, whether or not they changed.
+func (r *Reloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	state, err := pathState(r.path)
+	if err != nil {
+		return err
+	}
+	return r.reload(state)
+}
+
+// Watch checks the watched path for changes at the reloader interval and
+// reloads the fingerprints when it changed, until the context is done.
+func (r *Reloader) Watch(ctx context.Context) {
+	ticker := time.NewTicker(r.settings.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.reloadIfChanged()
+			if (changed || err != nil) && r.settings.OnReload != nil {
+				r.settings.OnReload(err)
+			}
+		}
+	}
+}
+
+// reloadIfChanged reloads the fingerprints if the watched path changed
+// since the last reload. An error checking the path is only returned
+// once until it changes.
+func (r *Reloader) reloadIfChanged() (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	state, err := pathState(r.path)
+	if err != nil {
+		errState := "error\n" + err.Error()
+		if errState == r.attempted {
+			return false, nil
+		}
+		r.attempted = errState
+		return false, err
+	}
+	if state == r.attempted {
+		return false, nil
+	}
+	return true, r.reload(state)
+}
+
+// reload loads, validates and swaps in the fingerprints. The mutex must be held.
+func (r *Reloader) reload(state string) error {
+	r.attempted = state
+
+	source := FileSource(r.path)
+	if strings.HasPrefix(state, "dir\n") {
+		source = DirectorySource(r.path)
+	}
+	loader := NewLoader(source)
+	if r.settings.LoadEmbedded {
+		loader = NewLoader(EmbeddedSource(), source)
+	}
+
+	wappalyze, err := NewFromLoader(loader, r.options...)
+	if err != nil {
+		return fmt.Errorf("could not reload fingerprints: %w", err)
+	}
+	if len(wappalyze.GetFingerprints().Apps) == 0 {
+		return fmt.Errorf("could not reload fingerprints: no apps found in %s", r.path)
+	}
+	if r.settings.Validate != nil {
+		if err := r.settings.Validate(wappalyze); err != nil {
+			return fmt.Errorf("could not validate reloaded fingerprints: %w", err)
+		}
+	}
+
+	r.current.Store(wappalyze)
+	r.state = state
+	return nil
+}
+
+// pathState returns a description of the fingerprints files of a path
+// changing whenever one of them is added, removed or modified.
+func pathState(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return fileState(path, info), nil
+	}
+
+	states := []string{"dir"}
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(file) != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		states = append(states, fileState(file, info))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(states[1:])
+	return strings.Join(states, "\n"), nil
+}
+
+// fileState returns a description of a file changing when it is modified
+func fileState(path string, info fs.FileInfo) string {
+	return fmt.Sprintf("%s %d %d", path, info.Size(), info.ModTime().UnixNano())
+}
@@ -0,0 +1,114 @@
+package wappalyzer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "custom.json")
+	// The file is replaced atomically, so that a watching reloader
+	// never sees it partially written.
+	writeFingerprints := func(data string, modTime time.Time) {
+		err := os.WriteFile(filePath+".tmp", []byte(data), 0644)
+		require.Nil(t, err, "could not write fingerprints file")
+		err = os.Chtimes(filePath+".tmp", modTime, modTime)
+		require.Nil(t, err, "could not set fingerprints file time")
+		err = os.Rename(filePath+".tmp", filePath)
+		require.Nil(t, err, "could not replace fingerprints file")
+	}
+	headers := map[string][]string{"X-Old": {"1"}, "X-New": {"1"}}
+
+	start := time.Now().Add(-time.Hour)
+	writeFingerprints(`{"apps": {"Old App": {"headers": {"x-old": ""}}}}`, start)
+
+	reloader, err := NewReloader(dir, ReloaderOptions{
+		Validate: func(wappalyze *Wappalyze) error {
+			if _, ok := wappalyze.GetFingerprints().Apps["Invalid App"]; ok {
+				return errors.New("invalid app")
+			}
+			return nil
+		},
+	})
+	require.Nil(t, err, "could not create reloader")
+	require.Equal(t, map[string]struct{}{"Old App": {}}, reloader.Fingerprint(headers, nil), "could not get correct match")
+
+	t.Run("unchanged", func(t *testing.T) {
+		changed, err := reloader.reloadIfChanged()
+		require.Nil(t, err, "could not check fingerprints")
+		require.False(t, changed, "could reload unchanged fingerprints")
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		snapshot := reloader.Current()
+		writeFingerprints(`{"apps": {"New App": {"headers": {"x-new": ""}}}}`, start.Add(time.Minute))
+
+		changed, err := reloader.reloadIfChanged()
+		require.Nil(t, err, "could not reload fingerprints")
+		require.True(t, changed, "could not detect changed fingerprints")
+		require.Equal(t, map[string]struct{}{"New App": {}}, reloader.Fingerprint(headers, nil), "could not get reloaded match")
+		require.Equal(t, map[string]struct{}{"Old App": {}}, snapshot.Fingerprint(headers, nil), "could modify previous instance")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		snapshot := reloader.Current()
+
+		writeFingerprints(`{"apps": {`, start.Add(2*time.Minute))
+		_, err := reloader.reloadIfChanged()
+		require.NotNil(t, err, "could reload invalid json")
+		require.Same(t, snapshot, reloader.Current(), "could swap invalid json")
+
+		writeFingerprints(`{"apps": {"Invalid App": {"headers": {"x-new": ""}}}}`, start.Add(3*time.Minute))
+		_, err = reloader.reloadIfChanged()
+		require.NotNil(t, err, "could reload fingerprints failing validation")
+		require.Same(t, snapshot, reloader.Current(), "could swap fingerprints failing validation")
+
+		changed, err := reloader.reloadIfChanged()
+		require.Nil(t, err, "could retry failed reload")
+		require.False(t, changed, "could retry failed reload")
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		reloaded := make(chan error, 10)
+		watched, err := NewReloader(dir, ReloaderOptions{
+			Interval: 10 * time.Millisecond,
+			OnReload: func(err error) {
+				reloaded <- err
+			},
+		})
+		require.Nil(t, err, "could not create reloader")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go watched.Watch(ctx)
+
+		writeFingerprints(`{"apps": {`, start.Add(4*time.Minute))
+		select {
+		case err := <-reloaded:
+			require.NotNil(t, err, "could reload invalid json")
+		case <-time.After(5 * time.Second):
+			t.Fatal("could not watch fingerprints")
+		}
+		select {
+		case err := <-reloaded:
+			t.Fatalf("could retry failed reload: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		writeFingerprints(`{"apps": {"Old App": {"headers": {"x-old": ""}}}}`, start.Add(5*time.Minute))
+		select {
+		case err := <-reloaded:
+			require.Nil(t, err, "could not reload fingerprints")
+		case <-time.After(5 * time.Second):
+			t.Fatal("could not watch fingerprints")
+		}
+		require.Equal(t, map[string]struct{}{"Old App": {}}, watched.Fingerprint(headers, nil), "could not get watched match")
+	})
+}
@@ -0,0 +1,257 @@
+package wappalyzer
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// impliedTechnology is a single parsed entry of the implies field
+// of a fingerprint, such as "PHP\;confidence:50".
+type impliedTechnology struct {
+	name       string
+	confidence int
+	version    string
+}
+
+// parseImplies parses the implies entries of a fingerprint along with
+// their confidence and version modifiers.
+func parseImplies(implies []string) []impliedTechnology {
+	parsed := make([]impliedTechnology, 0, len(implies))
+	for _, entry := range implies {
+		parts := strings.Split(entry, "\\;")
+
+		implied := impliedTechnology{
+			name:       strings.TrimSpace(parts[0]),
+			confidence: 100,
+		}
+		if implied.name == "" {
+			continue
+		}
+		for _, part := range parts[1:] {
+			keyValue := strings.SplitN(part, ":", 2)
+			if len(keyValue) < 2 {
+				continue
+			}
+
+			switch keyValue[0] {
+			case "confidence":
+				if conf, err := strconv.Atoi(keyValue[1]); err == nil {
+					implied.confidence = conf
+				}
+			case "version":
+				implied.version = keyValue[1]
+			}
+		}
+		parsed = append(parsed, implied)
+	}
+	return parsed
+}
+
+// impliedItem is a technology queued for implies resolution
+type impliedItem struct {
+	name       string
+	confidence int
+	path       []string
+}
+
+// impliedMatch is the strongest implication of a technology found
+// across the walks of all the detected technologies.
+type impliedMatch struct {
+	confidence int
+	version    string
+	impliedBy  []string
+	path       []string
+}
+
+// resolveImplies walks the implies of every matched technology transitively
+// and adds the implied technologies to the unique fingerprints.
+//
+// The confidence of an implied technology is the confidence of its parent
+// scaled by the confidence of the implies entry. A technology implied by
+// several walks, or also matched directly, keeps the highest of these
+// confidences rather than their sum. Each walk keeps track of visited
+// technologies so that cyclic implies chains terminate. Blocked
+// technologies are neither added nor walked.
+func (f *CompiledFingerprints) resolveImplies(u UniqueFingerprints, blocked map[string]struct{}) {
+	roots := make([]impliedItem, 0, len(u.values))
+	for name, metadata := range u.values {
+		if metadata.confidence == 0 {
+			continue
+		}
+		roots = append(roots, impliedItem{name: name, confidence: metadata.confidence})
+	}
+	// Walk in a stable order so that implication paths are deterministic
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].name < roots[j].name
+	})
+
+	matches := make(map[string]*impliedMatch)
+	for _, root := range roots {
+		visited := map[string]struct{}{root.name: {}}
+
+		queue := []impliedItem{root}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+
+			fingerprint, ok := f.Apps[current.name]
+			if !ok {
+				continue
+			}
+			path := append(current.path[:len(current.path):len(current.path)], current.name)
+
+			for _, implied := range fingerprint.implies {
+				if _, ok := visited[implied.name]; ok {
+					continue
+				}
+				visited[implied.name] = struct{}{}
+				if _, ok := blocked[implied.name]; ok {
+					continue
+				}
+
+				confidence := current.confidence * implied.confidence / 100
+				match, ok := matches[implied.name]
+				if !ok {
+					match = &impliedMatch{}
+					matches[implied.name] = match
+				}
+				if confidence > match.confidence {
+					match.confidence = confidence
+					match.path = path
+				}
+				if match.version == "" {
+					match.version = implied.version
+				}
+				if !slices.Contains(match.impliedBy, current.name) {
+					match.impliedBy = append(match.impliedBy, current.name)
+				}
+
+				queue = append(queue, impliedItem{
+					name:       implied.name,
+					confidence: confidence,
+					path:       path,
+				})
+			}
+		}
+	}
+
+	for name, match := range matches {
+		metadata, alreadyDetected := u.values[name]
+		if !alreadyDetected {
+			metadata.implicationPath = match.path
+		}
+		metadata.confidence = max(metadata.confidence, match.confidence)
+		if metadata.version == "" {
+			metadata.version = match.version
+		}
+		for _, parent := range match.impliedBy {
+			if !slices.Contains(metadata.impliedBy, parent) {
+				metadata.impliedBy = append(metadata.impliedBy, parent)
+			}
+		}
+		u.values[name] = metadata
+	}
+}
+
+// resolve runs the post-processing stage on the technologies matched
+// directly from the response parts. It resolves implies and enforces
+// the requires, requiresCategory and excludes semantics.
+//
+// Technologies failing their requirements or excluded by another detected
+// technology are blocked, and resolution is repeated until no more
+// technologies get blocked, so that removals cascade to the technologies
+// they implied or satisfied.
+func (f *CompiledFingerprints) resolve(direct UniqueFingerprints) UniqueFingerprints {
+	blocked := make(map[string]struct{})
+
+	for {
+		resolved := direct.clone()
+		for name := range blocked {
+			delete(resolved.values, name)
+		}
+		f.resolveImplies(resolved, blocked)
+
+		rejected := f.rejectedTechnologies(resolved)
+		if len(rejected) == 0 {
+			return resolved
+		}
+		for _, name := range rejected {
+			blocked[name] = struct{}{}
+		}
+	}
+}
+
+// rejectedTechnologies returns the technologies that fail their requires
+// or requiresCategory constraints or are excluded by other technologies.
+func (f *CompiledFingerprints) rejectedTechnologies(u UniqueFingerprints) []string {
+	detected := make([]string, 0, len(u.values))
+	categories := make(map[int]int)
+	for name, metadata := range u.values {
+		if metadata.confidence == 0 {
+			continue
+		}
+		detected = append(detected, name)
+
+		if fingerprint, ok := f.Apps[name]; ok {
+			for _, cat := range fingerprint.cats {
+				categories[cat]++
+			}
+		}
+	}
+	sort.Strings(detected)
+
+	var rejected []string
+	excluded := make(map[string]struct{})
+	for _, name := range detected {
+		fingerprint, ok := f.Apps[name]
+		if !ok {
+			continue
+		}
+		if !u.hasAll(fingerprint.requires) || !hasAllCategories(categories, fingerprint) {
+			rejected = append(rejected, name)
+			continue
+		}
+		// Technologies excluded earlier do not exclude others
+		if _, ok := excluded[name]; ok {
+			continue
+		}
+		for _, exclude := range fingerprint.excludes {
+			if metadata, ok := u.values[exclude]; !ok || metadata.confidence == 0 {
+				continue
+			}
+			if _, ok := excluded[exclude]; ok {
+				continue
+			}
+			excluded[exclude] = struct{}{}
+			rejected = append(rejected, exclude)
+		}
+	}
+	return rejected
+}
+
+// hasAll returns true if all the technologies are detected
+func (u UniqueFingerprints) hasAll(names []string) bool {
+	for _, name := range names {
+		if metadata, ok := u.values[name]; !ok || metadata.confidence == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAllCategories returns true if at least one other technology is
+// detected in each of the categories required by the fingerprint.
+func hasAllCategories(detected map[int]int, fingerprint *CompiledFingerprint) bool {
+	for _, cat := range fingerprint.requiresCategory {
+		count := detected[cat]
+		if slices.Contains(fingerprint.cats, cat) {
+			count--
+		}
+		if count <= 0 {
+			return false
+		}
+	}
+	return true
+}
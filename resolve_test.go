@@ -0,0 +1,120 @@
+package wappalyzer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImplies(t *testing.T) {
+	implies := parseImplies([]string{"PHP", "SQLite\\;confidence:80", "Magento\\;version:2", ""})
+	require.Equal(t, []impliedTechnology{
+		{name: "PHP", confidence: 100},
+		{name: "SQLite", confidence: 80},
+		{name: "Magento", confidence: 100, version: "2"},
+	}, implies, "could not parse implies")
+}
+
+func TestResolveImplies(t *testing.T) {
+	fingerprints := &CompiledFingerprints{Apps: map[string]*CompiledFingerprint{
+		"Theme":    {implies: parseImplies([]string{"CMS"})},
+		"CMS":      {implies: parseImplies([]string{"Language\\;confidence:50", "Database\\;version:8"})},
+		"Language": {implies: parseImplies([]string{"Theme", "Runtime"})},
+		"Database": {},
+		"Runtime":  {},
+	}}
+
+	u := NewUniqueFingerprints()
+	u.SetIfNotExists("Theme", "", 100)
+	fingerprints.resolveImplies(u, nil)
+
+	require.Equal(t, map[string]int{
+		"Theme":      100,
+		"CMS":        100,
+		"Language":   50,
+		"Database:8": 100,
+		"Runtime":    50,
+	}, u.GetValuesWithConfidence(), "could not resolve implies transitively")
+
+	require.Equal(t, []string{"Language"}, u.values["Runtime"].impliedBy, "could not get implied by")
+	require.Equal(t, []string{"Theme", "CMS", "Language"}, u.values["Runtime"].implicationPath, "could not get implication path")
+	require.Empty(t, u.values["Theme"].implicationPath, "got implication path for direct match")
+}
+
+func TestResolveImpliesMultipleRoots(t *testing.T) {
+	fingerprints := &CompiledFingerprints{Apps: map[string]*CompiledFingerprint{
+		"Framework": {implies: parseImplies([]string{"Library\\;confidence:50"})},
+		"Library":   {implies: parseImplies([]string{"PHP\\;confidence:50"})},
+		"Left":      {implies: parseImplies([]string{"Runtime\\;confidence:40"})},
+		"Right":     {implies: parseImplies([]string{"Runtime\\;confidence:60"})},
+		"PHP":       {},
+		"Runtime":   {},
+	}}
+
+	t.Run("chain", func(t *testing.T) {
+		u := NewUniqueFingerprints()
+		u.SetIfNotExists("Framework", "", 100)
+		u.SetIfNotExists("Library", "", 50)
+		fingerprints.resolveImplies(u, nil)
+
+		require.Equal(t, map[string]int{
+			"Framework": 100,
+			"Library":   50,
+			"PHP":       25,
+		}, u.GetValuesWithConfidence(), "could not keep the highest implied confidence")
+		require.Equal(t, []string{"Library"}, u.values["PHP"].impliedBy, "could not get implied by")
+	})
+
+	t.Run("diamond", func(t *testing.T) {
+		u := NewUniqueFingerprints()
+		u.SetIfNotExists("Left", "", 100)
+		u.SetIfNotExists("Right", "", 100)
+		fingerprints.resolveImplies(u, nil)
+
+		require.Equal(t, 60, u.values["Runtime"].confidence, "could not keep the highest implied confidence")
+		require.Equal(t, []string{"Left", "Right"}, u.values["Runtime"].impliedBy, "could not get all implied by")
+		require.Equal(t, []string{"Right"}, u.values["Runtime"].implicationPath, "could not get strongest implication path")
+	})
+}
+
+func TestResolveConstraints(t *testing.T) {
+	fingerprints := &CompiledFingerprints{Apps: map[string]*CompiledFingerprint{
+		"CMS":           {cats: []int{1}, implies: parseImplies([]string{"PHP"})},
+		"Theme":         {requires: []string{"CMS"}},
+		"Plugin":        {requiresCategory: []int{1}, implies: parseImplies([]string{"Library"})},
+		"Library":       {},
+		"PHP":           {},
+		"Other CMS":     {cats: []int{1}, excludes: []string{"CMS"}},
+		"Orphan Plugin": {cats: []int{1}, requiresCategory: []int{1}},
+	}}
+
+	t.Run("satisfied", func(t *testing.T) {
+		u := NewUniqueFingerprints()
+		u.SetIfNotExists("CMS", "", 100)
+		u.SetIfNotExists("Theme", "", 100)
+		u.SetIfNotExists("Plugin", "", 100)
+
+		resolved := fingerprints.resolve(u)
+		require.Equal(t, map[string]struct{}{"CMS": {}, "PHP": {}, "Theme": {}, "Plugin": {}, "Library": {}}, resolved.GetValues(), "could not resolve satisfied constraints")
+	})
+
+	t.Run("requires", func(t *testing.T) {
+		u := NewUniqueFingerprints()
+		u.SetIfNotExists("Theme", "", 100)
+		u.SetIfNotExists("Plugin", "", 100)
+		u.SetIfNotExists("Orphan Plugin", "", 100)
+
+		resolved := fingerprints.resolve(u)
+		require.Empty(t, resolved.GetValues(), "could not enforce requires")
+	})
+
+	t.Run("excludes", func(t *testing.T) {
+		u := NewUniqueFingerprints()
+		u.SetIfNotExists("CMS", "", 100)
+		u.SetIfNotExists("Theme", "", 100)
+		u.SetIfNotExists("Other CMS", "", 100)
+
+		resolved := fingerprints.resolve(u)
+		require.Equal(t, map[string]struct{}{"Other CMS": {}}, resolved.GetValues(), "could not cascade excludes")
+	})
+}
@@ -2,9 +2,10 @@ package wappalyzer
 
 import (
 	"bytes"
-	"encoding/json"
-	"fmt"
-	"os"
+	"context"
+	"io/fs"
+	"slices"
+	"sort"
 	"strings"
 )
 
@@ -12,21 +13,30 @@ import (
 type Wappalyze struct {
 	original     *Fingerprints
 	fingerprints *CompiledFingerprints
+	// loadReport describes where the loaded fingerprints came from
+	loadReport *LoadReport
+
+	// evidence indicates whether to record the evidence trail
+	evidence bool
+	// minConfidence is the minimum confidence for a detection to be reported
+	minConfidence int
+	// maxScriptBytes is the maximum number of inline script bytes scanned per page
+	maxScriptBytes int
+	// resourceFetcher fetches external scripts and stylesheets
+	resourceFetcher ResourceFetcher
+	// maxBodySize is the maximum number of body bytes read by FingerprintReader
+	maxBodySize int64
+	// cachePath is the path of the fingerprints cache file, if any
+	cachePath string
+	// validatePatterns compiles all the patterns at load, failing on invalid ones
+	validatePatterns bool
+	// strict fails the load on any invalid rule
+	strict bool
 }
 
 // New creates a new tech detection instance
-func New() (*Wappalyze, error) {
-	wappalyze := &Wappalyze{
-		fingerprints: &CompiledFingerprints{
-			Apps: make(map[string]*CompiledFingerprint),
-		},
-	}
-
-	err := wappalyze.loadFingerprints()
-	if err != nil {
-		return nil, err
-	}
-	return wappalyze, nil
+func New(options ...Option) (*Wappalyze, error) {
+	return NewFromLoader(NewLoader(EmbeddedSource()), options...)
 }
 
 // NewFromFile creates a new tech detection instance from a file
@@ -34,21 +44,70 @@ func New() (*Wappalyze, error) {
 // loadEmbedded indicates whether to load the embedded fingerprints
 // supersede indicates whether to overwrite the embedded fingerprints (if loaded) with the file fingerprints if the app name conflicts
 // supersede is only used if loadEmbedded is true
-func NewFromFile(filePath string, loadEmbedded, supersede bool) (*Wappalyze, error) {
+func NewFromFile(filePath string, loadEmbedded, supersede bool, options ...Option) (*Wappalyze, error) {
+	if !loadEmbedded {
+		return NewFromLoader(NewLoader(FileSource(filePath)), options...)
+	}
+
+	strategy := MergeSkip
+	if supersede {
+		strategy = MergeReplace
+	}
+	return NewFromLoader(NewLoader(EmbeddedSource(), FileSource(filePath).WithStrategy(strategy)), options...)
+}
+
+// NewFromFS creates a new tech detection instance from the fingerprints
+// JSON files of a file system, using the per-category layout of the
+// embedded data/fingerprints directory.
+func NewFromFS(fsys fs.FS, options ...Option) (*Wappalyze, error) {
+	return NewFromLoader(NewLoader(FSSource("fs", fsys)), options...)
+}
+
+// NewFromDirectory creates a new tech detection instance from the
+// fingerprints JSON files of a directory, such as data/fingerprints.
+func NewFromDirectory(dirPath string, options ...Option) (*Wappalyze, error) {
+	return NewFromLoader(NewLoader(DirectorySource(dirPath)), options...)
+}
+
+// NewFromLoader creates a new tech detection instance from the
+// fingerprints of a loader. The load report is available with LoadReport.
+func NewFromLoader(loader *Loader, options ...Option) (*Wappalyze, error) {
+	if _, err := loadCategories(); err != nil {
+		return nil, err
+	}
+
 	wappalyze := &Wappalyze{
 		fingerprints: &CompiledFingerprints{
 			Apps: make(map[string]*CompiledFingerprint),
 		},
 	}
+	for _, option := range options {
+		option(wappalyze)
+	}
 
-	err := wappalyze.loadFingerprintsFromFile(filePath, loadEmbedded, supersede)
+	if wappalyze.cachePath != "" {
+		if err := wappalyze.loadCached(loader); err != nil {
+			return nil, err
+		}
+		return wappalyze, nil
+	}
+
+	fingerprintsStruct, report, err := loader.Load()
 	if err != nil {
 		return nil, err
 	}
-
+	wappalyze.loadReport = report
+	if err := wappalyze.loadFingerprints(fingerprintsStruct); err != nil {
+		return nil, err
+	}
 	return wappalyze, nil
 }
 
+// LoadReport returns where the loaded fingerprints came from
+func (s *Wappalyze) LoadReport() *LoadReport {
+	return s.loadReport
+}
+
 // GetFingerprints returns the original fingerprints
 func (s *Wappalyze) GetFingerprints() *Fingerprints {
 	return s.original
@@ -59,108 +118,257 @@ func (s *Wappalyze) GetCompiledFingerprints() *CompiledFingerprints {
 	return s.fingerprints
 }
 
-// loadFingerprints loads the fingerprints and compiles them
-func (s *Wappalyze) loadFingerprints() error {
-	var fingerprintsStruct Fingerprints
-	err := json.Unmarshal([]byte(fingerprints), &fingerprintsStruct)
-	if err != nil {
-		return err
+// loadFingerprints compiles the loaded fingerprints, collecting the
+// diagnostics of the invalid rules in the load report.
+func (s *Wappalyze) loadFingerprints(fingerprintsStruct *Fingerprints) error {
+	s.original = fingerprintsStruct
+	for i, fingerprint := range fingerprintsStruct.Apps {
+		s.fingerprints.Apps[i] = compileFingerprint(i, fingerprint)
 	}
 
-	s.original = &fingerprintsStruct
-	for i, fingerprint := range fingerprintsStruct.Apps {
-		s.fingerprints.Apps[i] = compileFingerprint(fingerprint)
+	// Patterns are only compiled upfront when validating them
+	var patterns []Diagnostic
+	if s.validatePatterns || s.strict {
+		patterns = s.fingerprints.compilePatterns()
+	} else {
+		patterns = s.fingerprints.validatePatterns()
+	}
+	diagnostics := append(s.fingerprints.compileDiagnostics(), patterns...)
+	sortDiagnostics(diagnostics)
+
+	if s.loadReport == nil {
+		s.loadReport = &LoadReport{}
+	}
+	s.loadReport.Diagnostics = diagnostics
+
+	switch {
+	case s.strict && len(diagnostics) > 0:
+		return &DiagnosticsError{Diagnostics: diagnostics}
+	case s.validatePatterns && len(patterns) > 0:
+		return &DiagnosticsError{Diagnostics: patterns}
 	}
 	return nil
 }
 
-// loadFingerprints loads the fingerprints from the provided file and compiles them
-func (s *Wappalyze) loadFingerprintsFromFile(filePath string, loadEmbedded, supersede bool) error {
+// Diagnostics returns the fingerprint rules that could not be compiled
+// and are ignored when matching, sorted by app. They are collected when
+// loading the fingerprints, see LoadReport.
+func (s *Wappalyze) Diagnostics() []Diagnostic {
+	if s.loadReport == nil {
+		return nil
+	}
+	return s.loadReport.Diagnostics
+}
+
+// Detection is a single technology detected on a target along with
+// the metadata gathered while matching it.
+type Detection struct {
+	// Name is the name of the technology as present in the fingerprints
+	Name string `json:"name"`
+	// Version is the version extracted for the technology, if any
+	Version string `json:"version,omitempty"`
+	// Confidence is the final accumulated confidence, capped at 100
+	Confidence int `json:"confidence"`
+	// Cats contains the category IDs of the technology
+	Cats []int `json:"cats,omitempty"`
+	// Categories contains the category names of the technology
+	Categories []string `json:"categories,omitempty"`
+	// CPE is the cpe for the technology, if any
+	CPE string `json:"cpe,omitempty"`
+	// Description contains the technology description
+	Description string `json:"description,omitempty"`
+	// Website contains a URL associated with the technology
+	Website string `json:"website,omitempty"`
+	// Icon contains the icon associated with the technology
+	Icon string `json:"icon,omitempty"`
+	// ImpliedBy contains the technologies that implied this one
+	ImpliedBy []string `json:"implied_by,omitempty"`
+	// ImplicationPath is the chain of technologies, starting from a directly
+	// matched one, through which this technology was implied. It is empty
+	// for directly matched technologies.
+	ImplicationPath []string `json:"implication_path,omitempty"`
+	// Evidence contains the rules that matched for the technology.
+	// It is only populated when WithEvidence option is used.
+	Evidence []Evidence `json:"evidence,omitempty"`
+}
+
+// AppInfo returns the basic information about the detected technology
+func (d Detection) AppInfo() AppInfo {
+	return AppInfo{
+		Description: d.Description,
+		Website:     d.Website,
+		CPE:         d.CPE,
+		Icon:        d.Icon,
+		Categories:  d.Categories,
+	}
+}
+
+// FingerprintDetailed identifies technologies on a target,
+// based on the received response headers and body.
+// It returns one typed Detection per technology, sorted by name.
+//
+// Body should not be mutated while this function is being called, or it may
+// lead to unexpected things.
+func (s *Wappalyze) FingerprintDetailed(headers map[string][]string, body []byte) []Detection {
+	// The background context is never done, so no error is returned
+	detections, _ := s.FingerprintDetailedContext(context.Background(), headers, body)
+	return detections
+}
 
-	f, err := os.ReadFile(filePath)
+// FingerprintDetailedContext is like FingerprintDetailed but stops once
+// the context is done, returning a *CancelledError.
+func (s *Wappalyze) FingerprintDetailedContext(ctx context.Context, headers map[string][]string, body []byte) ([]Detection, error) {
+	normalizedHeaders := s.normalizeHeaders(headers)
+	uniqueFingerprints, err := s.fingerprint(ctx, normalizedHeaders, body)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return s.detections(uniqueFingerprints), nil
+}
 
-	var fingerprintsStruct Fingerprints
-	err = json.Unmarshal(f, &fingerprintsStruct)
+// Fingerprint identifies technologies on a target,
+// based on the received response headers and body.
+//
+// Body should not be mutated while this function is being called, or it may
+// lead to unexpected things.
+func (s *Wappalyze) Fingerprint(headers map[string][]string, body []byte) map[string]struct{} {
+	return detectionsToValues(s.FingerprintDetailed(headers, body))
+}
+
+// FingerprintContext is like Fingerprint but stops once the context
+// is done, returning a *CancelledError.
+//
+// Cancellation is checked between the matched parts and while walking
+// the elements of the document.
+func (s *Wappalyze) FingerprintContext(ctx context.Context, headers map[string][]string, body []byte) (map[string]struct{}, error) {
+	detections, err := s.FingerprintDetailedContext(ctx, headers, body)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return detectionsToValues(detections), nil
+}
 
-	if len(fingerprintsStruct.Apps) == 0 {
-		return fmt.Errorf("no fingerprints found in file: %s", filePath)
+// fingerprint runs all the matchers on normalized headers and the raw body.
+// Body is skipped entirely when nil.
+func (s *Wappalyze) fingerprint(ctx context.Context, normalizedHeaders map[string]string, body []byte) (UniqueFingerprints, error) {
+	uniqueFingerprints, err := s.fingerprintHeaders(ctx, normalizedHeaders)
+	if err != nil {
+		return uniqueFingerprints, err
 	}
 
-	if loadEmbedded {
-		var embedded Fingerprints
-		err := json.Unmarshal([]byte(fingerprints), &embedded)
-		if err != nil {
-			return err
+	// Check for stuff in the body finally
+	if body != nil {
+		if err := checkContext(ctx, htmlPart); err != nil {
+			return uniqueFingerprints, err
 		}
 
-		s.original = &embedded
-
-		for app, fingerprint := range fingerprintsStruct.Apps {
-			if _, ok := s.original.Apps[app]; ok && supersede {
-				s.original.Apps[app] = fingerprint
-			} else {
-				s.original.Apps[app] = fingerprint
-			}
+		// Lowercase everything that we have received to check
+		normalizedBody := bytes.ToLower(body)
+		for _, app := range s.checkBody(normalizedBody) {
+			uniqueFingerprints.setMatch(app)
 		}
 
-	} else {
-		s.original = &fingerprintsStruct
-	}
-
-	for i, fingerprint := range s.original.Apps {
-		s.fingerprints.Apps[i] = compileFingerprint(fingerprint)
+		// Evaluate the document on the original body as selectors
+		// and javascript globals are case-sensitive
+		elements, err := parseDocument(ctx, body)
+		if err != nil {
+			return uniqueFingerprints, err
+		}
+		technologies, err := s.checkDocument(ctx, elements)
+		if err != nil {
+			return uniqueFingerprints, err
+		}
+		for _, app := range technologies {
+			uniqueFingerprints.setMatch(app)
+		}
 	}
 
-	return nil
+	// Resolve implies, requires and excludes for everything matched so far
+	return s.fingerprints.resolve(uniqueFingerprints), nil
 }
 
-// Fingerprint identifies technologies on a target,
-// based on the received response headers and body.
-//
-// Body should not be mutated while this function is being called, or it may
-// lead to unexpected things.
-func (s *Wappalyze) Fingerprint(headers map[string][]string, body []byte) map[string]struct{} {
+// fingerprintHeaders runs the header and cookie matchers on normalized headers
+func (s *Wappalyze) fingerprintHeaders(ctx context.Context, normalizedHeaders map[string]string) (UniqueFingerprints, error) {
 	uniqueFingerprints := NewUniqueFingerprints()
+	uniqueFingerprints.recordEvidence = s.evidence
 
-	// Lowercase everything that we have received to check
-	normalizedBody := bytes.ToLower(body)
-	normalizedHeaders := s.normalizeHeaders(headers)
-
+	if err := checkContext(ctx, headersPart); err != nil {
+		return uniqueFingerprints, err
+	}
 	// Run header based fingerprinting if the number
 	// of header checks if more than 0.
 	for _, app := range s.checkHeaders(normalizedHeaders) {
-		uniqueFingerprints.SetIfNotExists(app.application, app.version, app.confidence)
+		uniqueFingerprints.setMatch(app)
 	}
 
+	if err := checkContext(ctx, cookiesPart); err != nil {
+		return uniqueFingerprints, err
+	}
 	cookies := s.findSetCookie(normalizedHeaders)
 	// Run cookie based fingerprinting if we have a set-cookie header
 	if len(cookies) > 0 {
 		for _, app := range s.checkCookies(cookies) {
-			uniqueFingerprints.SetIfNotExists(app.application, app.version, app.confidence)
+			uniqueFingerprints.setMatch(app)
 		}
 	}
+	return uniqueFingerprints, nil
+}
 
-	// Check for stuff in the body finally
-	bodyTech := s.checkBody(normalizedBody)
-	for _, app := range bodyTech {
-		uniqueFingerprints.SetIfNotExists(app.application, app.version, app.confidence)
+// detections converts the matched fingerprints into a sorted list of detections
+func (s *Wappalyze) detections(u UniqueFingerprints) []Detection {
+	detections := make([]Detection, 0, len(u.values))
+	for name, v := range u.values {
+		if v.confidence == 0 || v.confidence < s.minConfidence {
+			continue
+		}
+		detection := Detection{
+			Name:            name,
+			Version:         v.version,
+			Confidence:      v.confidence,
+			ImpliedBy:       v.impliedBy,
+			ImplicationPath: v.implicationPath,
+			Evidence:        v.evidence,
+		}
+		if fingerprint, ok := s.fingerprints.Apps[name]; ok {
+			info := AppInfoFromFingerprint(fingerprint)
+			detection.Cats = fingerprint.cats
+			detection.Categories = info.Categories
+			detection.CPE = info.CPE
+			detection.Description = info.Description
+			detection.Website = info.Website
+			detection.Icon = info.Icon
+		}
+		detections = append(detections, detection)
 	}
-	return uniqueFingerprints.GetValues()
+	sort.Slice(detections, func(i, j int) bool {
+		return detections[i].Name < detections[j].Name
+	})
+	return detections
+}
+
+// detectionsToValues converts detections to the "name:version" set
+// returned by the legacy fingerprinting methods.
+func detectionsToValues(detections []Detection) map[string]struct{} {
+	values := make(map[string]struct{}, len(detections))
+	for _, detection := range detections {
+		values[FormatAppVersion(detection.Name, detection.Version)] = struct{}{}
+	}
+	return values
 }
 
 type UniqueFingerprints struct {
 	values map[string]uniqueFingerprintMetadata
+	// recordEvidence indicates whether evidence of matches is kept
+	recordEvidence bool
 }
 
 type uniqueFingerprintMetadata struct {
 	confidence int
 	version    string
+	impliedBy  []string
+	evidence   []Evidence
+	// implicationPath is the chain through which the tech was implied
+	implicationPath []string
 }
 
 func NewUniqueFingerprints() UniqueFingerprints {
@@ -169,6 +377,21 @@ func NewUniqueFingerprints() UniqueFingerprints {
 	}
 }
 
+// clone returns a deep copy of the unique fingerprints
+func (u UniqueFingerprints) clone() UniqueFingerprints {
+	cloned := UniqueFingerprints{
+		values:         make(map[string]uniqueFingerprintMetadata, len(u.values)),
+		recordEvidence: u.recordEvidence,
+	}
+	for k, v := range u.values {
+		v.impliedBy = slices.Clone(v.impliedBy)
+		v.evidence = slices.Clone(v.evidence)
+		v.implicationPath = slices.Clone(v.implicationPath)
+		cloned.values[k] = v
+	}
+	return cloned
+}
+
 func (u UniqueFingerprints) GetValues() map[string]struct{} {
 	values := make(map[string]struct{}, len(u.values))
 	for k, v := range u.values {
@@ -180,7 +403,18 @@ func (u UniqueFingerprints) GetValues() map[string]struct{} {
 	return values
 }
 
-const versionSeparator = ":"
+// GetValuesWithConfidence returns the "name:version" values along
+// with their accumulated confidence, capped at 100.
+func (u UniqueFingerprints) GetValuesWithConfidence() map[string]int {
+	values := make(map[string]int, len(u.values))
+	for k, v := range u.values {
+		if v.confidence == 0 {
+			continue
+		}
+		values[FormatAppVersion(k, v.version)] = v.confidence
+	}
+	return values
+}
 
 func (u UniqueFingerprints) SetIfNotExists(value, version string, confidence int) {
 	if _, ok := u.values[value]; ok {
@@ -203,10 +437,29 @@ func (u UniqueFingerprints) SetIfNotExists(value, version string, confidence int
 	}
 }
 
+// setMatch records a match result along with the technology implying it
+// and the evidence for the match if enabled.
+func (u UniqueFingerprints) setMatch(result matchPartResult) {
+	u.SetIfNotExists(result.application, result.version, result.confidence)
+
+	metadata := u.values[result.application]
+	if u.recordEvidence && len(result.evidence) > 0 {
+		metadata.evidence = append(metadata.evidence, cloneEvidence(result.evidence)...)
+	}
+	if result.impliedBy != "" && !slices.Contains(metadata.impliedBy, result.impliedBy) {
+		metadata.impliedBy = append(metadata.impliedBy, result.impliedBy)
+	}
+	u.values[result.application] = metadata
+}
+
 type matchPartResult struct {
 	application string
 	confidence  int
 	version     string
+	// impliedBy is the technology that implied this result, if any
+	impliedBy string
+	// evidence contains the patterns that matched for this result
+	evidence []Evidence
 }
 
 // FingerprintWithTitle identifies technologies on a target,
@@ -216,36 +469,18 @@ type matchPartResult struct {
 // Body should not be mutated while this function is being called, or it may
 // lead to unexpected things.
 func (s *Wappalyze) FingerprintWithTitle(headers map[string][]string, body []byte) (map[string]struct{}, string) {
-	uniqueFingerprints := NewUniqueFingerprints()
-
-	// Lowercase everything that we have received to check
-	normalizedBody := bytes.ToLower(body)
 	normalizedHeaders := s.normalizeHeaders(headers)
 
-	// Run header based fingerprinting if the number
-	// of header checks if more than 0.
-	for _, app := range s.checkHeaders(normalizedHeaders) {
-		uniqueFingerprints.SetIfNotExists(app.application, app.version, app.confidence)
+	// Check for stuff in the body only for HTML responses
+	if !strings.Contains(normalizedHeaders["content-type"], "text/html") {
+		uniqueFingerprints, _ := s.fingerprint(context.Background(), normalizedHeaders, nil)
+		return detectionsToValues(s.detections(uniqueFingerprints)), ""
 	}
-
-	cookies := s.findSetCookie(normalizedHeaders)
-	// Run cookie based fingerprinting if we have a set-cookie header
-	if len(cookies) > 0 {
-		for _, app := range s.checkCookies(cookies) {
-			uniqueFingerprints.SetIfNotExists(app.application, app.version, app.confidence)
-		}
-	}
-
-	// Check for stuff in the body finally
-	if strings.Contains(normalizedHeaders["content-type"], "text/html") {
-		bodyTech := s.checkBody(normalizedBody)
-		for _, app := range bodyTech {
-			uniqueFingerprints.SetIfNotExists(app.application, app.version, app.confidence)
-		}
-		title := s.getTitle(body)
-		return uniqueFingerprints.GetValues(), title
+	if body == nil {
+		body = []byte{}
 	}
-	return uniqueFingerprints.GetValues(), ""
+	uniqueFingerprints, _ := s.fingerprint(context.Background(), normalizedHeaders, body)
+	return detectionsToValues(s.detections(uniqueFingerprints)), s.getTitle(body)
 }
 
 // FingerprintWithInfo identifies technologies on a target,
@@ -256,31 +491,23 @@ func (s *Wappalyze) FingerprintWithTitle(headers map[string][]string, body []byt
 // Body should not be mutated while this function is being called, or it may
 // lead to unexpected things.
 func (s *Wappalyze) FingerprintWithInfo(headers map[string][]string, body []byte) map[string]AppInfo {
-	apps := s.Fingerprint(headers, body)
-	result := make(map[string]AppInfo, len(apps))
+	detections := s.FingerprintDetailed(headers, body)
+	result := make(map[string]AppInfo, len(detections))
 
-	for app := range apps {
-		if fingerprint, ok := s.fingerprints.Apps[app]; ok {
-			result[app] = AppInfoFromFingerprint(fingerprint)
-		}
-
-		// Handle colon separated values
-		if strings.Contains(app, versionSeparator) {
-			if parts := strings.Split(app, versionSeparator); len(parts) == 2 {
-				if fingerprint, ok := s.fingerprints.Apps[parts[0]]; ok {
-					result[app] = AppInfoFromFingerprint(fingerprint)
-				}
-			}
+	for _, detection := range detections {
+		if _, ok := s.fingerprints.Apps[detection.Name]; !ok {
+			continue
 		}
+		result[FormatAppVersion(detection.Name, detection.Version)] = detection.AppInfo()
 	}
-
 	return result
 }
 
 func AppInfoFromFingerprint(fingerprint *CompiledFingerprint) AppInfo {
+	mapping := GetCategoriesMapping()
 	categories := make([]string, 0, len(fingerprint.cats))
 	for _, cat := range fingerprint.cats {
-		if category, ok := categoriesMapping[cat]; ok {
+		if category, ok := mapping[cat]; ok {
 			categories = append(categories, category.Name)
 		}
 	}
@@ -299,16 +526,16 @@ func AppInfoFromFingerprint(fingerprint *CompiledFingerprint) AppInfo {
 // Body should not be mutated while this function is being called, or it may
 // lead to unexpected things.
 func (s *Wappalyze) FingerprintWithCats(headers map[string][]string, body []byte) map[string]CatsInfo {
-	apps := s.Fingerprint(headers, body)
-	result := make(map[string]CatsInfo, len(apps))
-
-	for app := range apps {
-		if fingerprint, ok := s.fingerprints.Apps[app]; ok {
-			result[app] = CatsInfo{
-				Cats: fingerprint.cats,
-			}
+	detections := s.FingerprintDetailed(headers, body)
+	result := make(map[string]CatsInfo, len(detections))
+
+	for _, detection := range detections {
+		if _, ok := s.fingerprints.Apps[detection.Name]; !ok {
+			continue
+		}
+		result[FormatAppVersion(detection.Name, detection.Version)] = CatsInfo{
+			Cats: detection.Cats,
 		}
 	}
-
 	return result
 }
@@ -1,9 +1,17 @@
 package wappalyzer
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
 )
 
 func TestCookiesDetect(t *testing.T) {
@@ -54,6 +62,87 @@ func TestBodyDetect(t *testing.T) {
 		require.Contains(t, matches, "Mura CMS:1", "Could not get correct match")
 	})
 
+	t.Run("inline-script", func(t *testing.T) {
+		body := []byte(`<html><head>
+<script type="text/javascript">window.config = {"name": "6valley_cookie_consent"};</script>
+</head></html>`)
+
+		matches := wappalyzer.Fingerprint(map[string][]string{}, body)
+		require.Contains(t, matches, "6Valley eCommerce CMS", "Could not get correct inline script match")
+
+		limited, err := New(WithMaxScriptBytes(10))
+		require.Nil(t, err, "could not create wappalyzer")
+		require.NotContains(t, limited.Fingerprint(map[string][]string{}, body), "6Valley eCommerce CMS", "Could not limit inline script bytes")
+	})
+
+	t.Run("dom", func(t *testing.T) {
+		matches := wappalyzer.Fingerprint(map[string][]string{}, []byte(`<html>
+<body>
+<img src="https://images.contentstack.io/v3/assets/logo.png">
+<footer class="site-footer"><p>Designed and powered by <a>Jadu</a></p></footer>
+</body>
+</html>`))
+		require.Contains(t, matches, "Contentstack", "Could not get correct dom attribute match")
+		require.Contains(t, matches, "Jadu", "Could not get correct dom text match")
+	})
+
+	t.Run("js", func(t *testing.T) {
+		wappalyzer, err := New()
+		require.Nil(t, err, "could not create wappalyzer")
+
+		detections := wappalyzer.FingerprintDetailed(map[string][]string{}, []byte(`<html>
+<head>
+<script type="text/javascript">
+var AjaxPro = {};
+AjaxPro.version = "21.12.22.1";
+</script>
+</head>
+</html>`))
+
+		var found bool
+		for _, detection := range detections {
+			if detection.Name != "Ajax.NET Professional" {
+				continue
+			}
+			found = true
+			require.Equal(t, "21.12.22.1", detection.Version, "could not get correct version")
+			require.Equal(t, 50, detection.Confidence, "could not get lowered static js confidence")
+		}
+		require.True(t, found, "Could not get correct js match")
+
+		limited, err := New(WithMaxScriptBytes(10))
+		require.Nil(t, err, "could not create wappalyzer")
+		require.NotContains(t, limited.Fingerprint(map[string][]string{}, []byte(`<script>var AjaxPro = {}; AjaxPro.version = "21.12.22.1";</script>`)), "Ajax.NET Professional:21.12.22.1", "Could not limit js script bytes")
+	})
+
+	t.Run("css", func(t *testing.T) {
+		matches := wappalyzer.Fingerprint(map[string][]string{}, []byte(`<html>
+<head>
+<style>.MuiPaper-root { color: red; }</style>
+</head>
+<body>
+<div style="color: var(--nextui-colors-text)"></div>
+</body>
+</html>`))
+		require.Contains(t, matches, "MUI", "Could not get correct style block match")
+		require.Contains(t, matches, "HeroUI", "Could not get correct style attribute match")
+	})
+
+	t.Run("css-linked", func(t *testing.T) {
+		wappalyzer, err := New(WithResourceFetcher(func(_ context.Context, url string) ([]byte, error) {
+			require.Equal(t, "/static/app.css", url, "could not get correct stylesheet url")
+			return []byte(`.MuiPaper-root{margin:0}`), nil
+		}))
+		require.Nil(t, err, "could not create wappalyzer")
+
+		matches := wappalyzer.Fingerprint(map[string][]string{}, []byte(`<html>
+<head>
+<link rel="stylesheet" href="/static/app.css">
+</head>
+</html>`))
+		require.Contains(t, matches, "MUI", "Could not get correct linked stylesheet match")
+	})
+
 	t.Run("html-implied", func(t *testing.T) {
 		matches := wappalyzer.Fingerprint(map[string][]string{}, []byte(`<html data-ng-app="rbschangeapp">
 <head>
@@ -96,6 +185,18 @@ func TestUniqueFingerprints(t *testing.T) {
 		f.SetIfNotExists("test", "2.36.4", 100)
 		require.Equal(t, map[string]struct{}{"test:2.36.4": {}}, f.GetValues(), "could not get correct values")
 	})
+
+	t.Run("accumulated", func(t *testing.T) {
+		f := NewUniqueFingerprints()
+		f.SetIfNotExists("test", "", 25)
+		require.Equal(t, map[string]int{"test": 25}, f.GetValuesWithConfidence(), "could not get correct confidence")
+
+		f.SetIfNotExists("test", "1.0", 50)
+		require.Equal(t, map[string]int{"test:1.0": 75}, f.GetValuesWithConfidence(), "could not get correct confidence")
+
+		f.SetIfNotExists("test", "", 50)
+		require.Equal(t, map[string]int{"test:1.0": 100}, f.GetValuesWithConfidence(), "could not cap confidence")
+	})
 }
 
 func Test_FingerprintWithInfo(t *testing.T) {
@@ -115,3 +216,270 @@ func Test_FingerprintWithInfo(t *testing.T) {
 	require.Equal(t, "Liferay.svg", value.Icon, "could not get correct icon")
 	require.ElementsMatch(t, []string{"CMS"}, value.Categories, "could not get correct categories")
 }
+
+func Test_FingerprintDetailed(t *testing.T) {
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+
+	t.Run("version", func(t *testing.T) {
+		detections := wappalyzer.FingerprintDetailed(map[string][]string{
+			"liferay-portal": {"testserver 7.3.5"},
+		}, []byte(""))
+		require.Len(t, detections, 2, "could not get correct detections")
+		require.Equal(t, "Java", detections[0].Name, "could not get implied detection")
+		require.Equal(t, []string{"Liferay"}, detections[0].ImpliedBy, "could not get correct implied by")
+
+		detection := detections[1]
+		require.Equal(t, "Liferay", detection.Name, "could not get correct name")
+		require.Equal(t, "7.3.5", detection.Version, "could not get correct version")
+		require.Equal(t, 100, detection.Confidence, "could not get correct confidence")
+		require.Equal(t, "cpe:2.3:a:liferay:liferay_portal:*:*:*:*:*:*:*:*", detection.CPE, "could not get correct cpe")
+		require.ElementsMatch(t, []string{"CMS"}, detection.Categories, "could not get correct categories")
+		require.Empty(t, detection.ImpliedBy, "could not get correct implied by")
+	})
+
+	t.Run("implied", func(t *testing.T) {
+		detections := wappalyzer.FingerprintDetailed(map[string][]string{}, []byte(`<html data-ng-app="rbschangeapp"></html>`))
+
+		names := make([]string, 0, len(detections))
+		for _, detection := range detections {
+			names = append(names, detection.Name)
+			if detection.Name == "PHP" {
+				require.Equal(t, []string{"Proximis Unified Commerce"}, detection.ImpliedBy, "could not get correct implied by")
+			}
+		}
+		require.Equal(t, []string{"AngularJS", "PHP", "Proximis Unified Commerce"}, names, "could not get sorted detections")
+	})
+}
+
+func Test_FingerprintEvidence(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		wappalyzer, err := New()
+		require.Nil(t, err, "could not create wappalyzer")
+
+		detections := wappalyzer.FingerprintDetailed(map[string][]string{
+			"Server": {"now"},
+		}, []byte(""))
+		require.NotEmpty(t, detections, "could not get detections")
+		for _, detection := range detections {
+			require.Nil(t, detection.Evidence, "got evidence without evidence mode")
+		}
+
+		// The matchers do not build the evidence either
+		results := wappalyzer.fingerprints.matchMapString(map[string]string{"generator": "mura cms 1"}, metaPart, false)
+		results = append(results, wappalyzer.fingerprints.matchString(`<script src="https://code.jquery.com/jquery-3.6.0.min.js">`, htmlPart, false)...)
+		require.NotEmpty(t, results, "could not get matches")
+		for _, result := range results {
+			require.Nil(t, result.evidence, "got evidence without evidence mode")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		wappalyzer, err := New(WithEvidence())
+		require.Nil(t, err, "could not create wappalyzer")
+
+		detections := wappalyzer.FingerprintDetailed(map[string][]string{}, []byte(`<html>
+<head>
+<meta name="generator" content="mura cms 1">
+</head>
+</html>`))
+
+		var found bool
+		for _, detection := range detections {
+			if detection.Name != "Mura CMS" {
+				continue
+			}
+			found = true
+			require.Len(t, detection.Evidence, 1, "could not get correct evidence")
+
+			evidence := detection.Evidence[0]
+			require.Equal(t, "meta", evidence.Part, "could not get correct part")
+			require.Equal(t, "generator", evidence.Key, "could not get correct key")
+			require.Equal(t, "mura cms 1", evidence.Match, "could not get correct match")
+			require.Equal(t, wappalyzer.fingerprints.Apps["Mura CMS"].meta["generator"][0].Raw(), evidence.Pattern, "could not get correct pattern")
+			require.Equal(t, 100, evidence.Confidence, "could not get correct confidence")
+		}
+		require.True(t, found, "could not get mura cms detection")
+	})
+}
+
+func Test_MinConfidence(t *testing.T) {
+	headers := map[string][]string{
+		"X-ShopId": {"123"},
+	}
+
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+
+	detections := wappalyzer.FingerprintDetailed(headers, []byte(""))
+	require.Len(t, detections, 1, "could not get low confidence detection")
+	require.Equal(t, "Shopify", detections[0].Name, "could not get correct name")
+	require.Equal(t, 50, detections[0].Confidence, "could not get correct confidence")
+
+	filtered, err := New(WithMinConfidence(60))
+	require.Nil(t, err, "could not create wappalyzer")
+	require.Empty(t, filtered.FingerprintDetailed(headers, []byte("")), "could not suppress low confidence detection")
+	require.Empty(t, filtered.Fingerprint(headers, []byte("")), "could not suppress low confidence detection")
+}
+
+func Test_FingerprintReader(t *testing.T) {
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+
+	headers := map[string][]string{"Server": {"Apache/2.4.41"}}
+	body := `<html data-ng-app="rbschangeapp">
+<head>
+<meta name="generator" content="WordPress 6.4.2">
+<script src="https://code.jquery.com/jquery-3.6.0.min.js"></script>
+</head>
+</html>`
+
+	t.Run("equivalent", func(t *testing.T) {
+		matches, err := wappalyzer.FingerprintReader(headers, strings.NewReader(body))
+		require.Nil(t, err, "could not fingerprint reader")
+		require.Equal(t, wappalyzer.Fingerprint(headers, []byte(body)), matches, "could not get same matches as byte body")
+	})
+
+	t.Run("window", func(t *testing.T) {
+		// Place the html pattern across the boundary of the first window
+		padding := strings.Repeat("a", htmlWindowSize-17)
+		large := `<!--` + padding + `-->` + `<html data-ng-app="rbschangeapp"><body></body></html>`
+
+		matches, err := wappalyzer.FingerprintReader(nil, strings.NewReader(large))
+		require.Nil(t, err, "could not fingerprint reader")
+		require.Contains(t, matches, "Proximis Unified Commerce", "could not get match across windows")
+	})
+
+	t.Run("max-body-size", func(t *testing.T) {
+		limited, err := New(WithMaxBodySize(1024))
+		require.Nil(t, err, "could not create wappalyzer")
+
+		large := `<html><body>` + strings.Repeat("a", 2048) + `<meta name="generator" content="WordPress 6.4.2"></body></html>`
+		matches, err := limited.FingerprintReader(nil, strings.NewReader(large))
+		require.Nil(t, err, "could not fingerprint reader")
+		require.NotContains(t, matches, "WordPress:6.4.2", "could get match after max body size")
+	})
+
+	t.Run("large", func(t *testing.T) {
+		// The dom rules are not checked after the part kept in the tree
+		large := `<html><body>` + strings.Repeat(`<p>`+strings.Repeat("a", 1024)+`</p>`, 3*maxDocumentSize/1024) +
+			`<html data-ng-app="rbschangeapp"><meta name="generator" content="WordPress 6.4.2">` +
+			`<img src="https://images.contentstack.io/v3/assets/logo.png"></body></html>`
+		reader := strings.NewReader(large)
+
+		matches, err := wappalyzer.FingerprintReader(nil, reader)
+		require.Nil(t, err, "could not fingerprint reader")
+		require.Zero(t, reader.Len(), "could not read whole body")
+		require.Contains(t, matches, "Proximis Unified Commerce", "could not get html match after kept part")
+		require.Contains(t, matches, "WordPress:6.4.2", "could not get meta match after kept part")
+		require.NotContains(t, matches, "Contentstack", "could get dom match after kept part")
+
+		matches, err = wappalyzer.FingerprintReader(nil, strings.NewReader(`<html><body><img src="https://images.contentstack.io/v3/assets/logo.png"></body></html>`))
+		require.Nil(t, err, "could not fingerprint reader")
+		require.Contains(t, matches, "Contentstack", "could not get dom match")
+	})
+
+	t.Run("confidence", func(t *testing.T) {
+		custom, err := NewFromFS(fstest.MapFS{"custom.json": {Data: []byte(`{"apps": {"Custom App": {
+			"html": ["custom-one\\;confidence:30", "custom-two-([\\d.]+)\\;version:\\1\\;confidence:40"],
+			"meta": {"generator": ["custom\\;confidence:20"]}
+		}}}`)}})
+		require.Nil(t, err, "could not create wappalyzer")
+
+		// The html patterns match in different windows
+		body := `<html><head><meta name="generator" content="custom"></head><body>custom-one<!--` +
+			strings.Repeat("a", htmlWindowSize) + `-->custom-two-1.2</body></html>`
+		expected := custom.FingerprintDetailed(nil, []byte(body))
+		require.Len(t, expected, 1, "could not get detection")
+		require.Equal(t, 60, expected[0].Confidence, "could not get combined confidence")
+
+		detections, err := custom.FingerprintReaderDetailed(nil, strings.NewReader(body))
+		require.Nil(t, err, "could not fingerprint reader")
+		require.Equal(t, expected, detections, "could not get same detections as byte body")
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		stream := newDocumentStream()
+		tokenizer := html.NewTokenizer(strings.NewReader(`<ul id="list"><li>one<li>two</ul><p>text<br><script>var a = "<p>";</script>`))
+		var closed []string
+		for tokenType := tokenizer.Next(); tokenType != html.ErrorToken; tokenType = tokenizer.Next() {
+			for _, element := range stream.add(tokenizer, tokenType) {
+				closed = append(closed, element.Data+":"+elementText(element))
+			}
+		}
+		for _, element := range stream.close() {
+			closed = append(closed, element.Data+":"+elementText(element))
+		}
+		require.Equal(t, []string{"li:one", "li:two", "ul:onetwo", "br:", `script:var a = "<p>";`, `p:textvar a = "<p>";`}, closed, "could not close elements")
+
+		var tags []string
+		for _, element := range collectElements(stream.document) {
+			tags = append(tags, element.Data)
+		}
+		require.Equal(t, []string{"ul", "li", "li", "p", "br", "script"}, tags, "could not build tree")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := wappalyzer.FingerprintReader(nil, iotest.ErrReader(errors.New("connection reset")))
+		require.NotNil(t, err, "could not get reader error")
+	})
+}
+
+func Test_FingerprintContext(t *testing.T) {
+	wappalyzer, err := New()
+	require.Nil(t, err, "could not create wappalyzer")
+
+	headers := map[string][]string{"Server": {"Apache/2.4.41"}}
+	body := []byte(`<html><head><meta name="generator" content="WordPress 6.4.2"></head></html>`)
+
+	t.Run("completed", func(t *testing.T) {
+		matches, err := wappalyzer.FingerprintContext(context.Background(), headers, body)
+		require.Nil(t, err, "could not fingerprint with context")
+		require.Equal(t, wappalyzer.Fingerprint(headers, body), matches, "could not get same matches")
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := wappalyzer.FingerprintContext(ctx, headers, body)
+		var cancelled *CancelledError
+		require.True(t, errors.As(err, &cancelled), "could not get cancelled error")
+		require.Equal(t, "header", cancelled.Part, "could not get cancelled part")
+		require.ErrorIs(t, err, context.Canceled, "could not unwrap context error")
+
+		_, err = wappalyzer.FingerprintReaderContext(ctx, nil, bytes.NewReader(body))
+		require.True(t, errors.As(err, &cancelled), "could not get cancelled reader error")
+	})
+
+	t.Run("fetcher", func(t *testing.T) {
+		fetching := make(chan struct{})
+		wappalyzer, err := New(WithResourceFetcher(func(ctx context.Context, url string) ([]byte, error) {
+			close(fetching)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}))
+		require.Nil(t, err, "could not create wappalyzer")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-fetching
+			cancel()
+		}()
+
+		_, err = wappalyzer.FingerprintContext(ctx, headers, []byte(`<html><head><script src="/static/app.js"></script></head></html>`))
+		var cancelled *CancelledError
+		require.True(t, errors.As(err, &cancelled), "could not get cancelled error")
+		require.Equal(t, "js", cancelled.Part, "could not get cancelled part")
+		require.ErrorIs(t, err, context.Canceled, "could not unwrap context error")
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+		<-ctx.Done()
+
+		_, err := wappalyzer.FingerprintDetailedContext(ctx, nil, body)
+		require.ErrorIs(t, err, context.DeadlineExceeded, "could not get deadline error")
+	})
+}